@@ -2,27 +2,65 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"stellarspec/internal/hook"
 	config "stellarspec/internal/model/conf"
 	"stellarspec/internal/reviewer"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// flag 变量
+	provider      string
 	apiServer     string
+	apiVersion    string
 	model         string
 	key           string
 	language      string
 	confPath      string
 	maxPool       int
 	commitID      string
+	baseRef       string
+	headRef       string
 	promptFile    string
 	thinkingChain bool
+	unified       int
+	adaptivePool  bool
+	format        string
+	includes      []string
+	excludes      []string
+	noDefIgnore   bool
+	renameScore   int
+	maxFileSize   int
+	chunkTokens   int
+	batch         bool
+	summaryPass   bool
+	contextMode   string
+	retries       int
+	timeout       time.Duration
+	noCache       bool
+	pruneOlder    time.Duration
+	output        string
+	outputMode    string
+	sortBy        string
+	failOn        string
+	staged        bool
+	hookPreCommit bool
+	hookPrePush   bool
+	hookFailOn    string
+)
+
+// stellar review 的退出码，便于 CI 区分需要处理的问题与运行失败
+const (
+	exitFindings = 1 // 存在达到 --fail-on 等级的问题
+	exitFailure  = 2 // 参数、配置、git 或模型调用出错，或有文件审查失败
 )
 
 var rootCmd = &cobra.Command{
@@ -34,7 +72,7 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		// 如果只是设置配置，不需要额外操作
 		// 配置已经在 PersistentPreRun 中处理了
-		if apiServer != "" || model != "" || key != "" || language != "" {
+		if provider != "" || apiServer != "" || apiVersion != "" || model != "" || key != "" || language != "" {
 			fmt.Println("配置设置完成")
 			return
 		}
@@ -56,6 +94,15 @@ func getDefaultConfigPath() string {
 	return filepath.Join(configDir, "cnf")
 }
 
+// 获取审查结果缓存目录
+func getDefaultCacheDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".stellarspec", "cache")
+	}
+	return filepath.Join(homeDir, ".stellarspec", "cache")
+}
+
 // 确保配置目录存在
 func ensureConfigDir(configPath string) error {
 	configDir := filepath.Dir(configPath)
@@ -72,6 +119,8 @@ func ensureConfigDir(configPath string) error {
 }
 
 // 添加处理配置的函数
+//
+// 作为 PersistentPreRun 也在 review 之前执行，出错时使用 exitFailure，避免与「存在达到 --fail-on 等级的问题」混淆
 func handleConfigFlags() {
 	// 默认配置文件路径
 	configPath := getDefaultConfigPath()
@@ -82,23 +131,47 @@ func handleConfigFlags() {
 	// 确保配置目录存在
 	if err := ensureConfigDir(configPath); err != nil {
 		fmt.Printf("创建配置目录失败: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitFailure)
+	}
+
+	// 如果有设置模型服务提供方
+	if provider != "" {
+		switch provider {
+		case reviewer.ProviderOpenAI, reviewer.ProviderOllama, reviewer.ProviderAnthropic, reviewer.ProviderAzure:
+		default:
+			fmt.Printf("不支持的服务提供方: %s (仅支持 openai、ollama、anthropic 或 azure)\n", provider)
+			os.Exit(exitFailure)
+		}
+		if err := config.SaveProvider(provider, configPath); err != nil {
+			fmt.Printf("保存服务提供方配置失败: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		fmt.Printf("服务提供方已设置为: %s\n", provider)
 	}
 
 	// 如果有设置 API 服务器
 	if apiServer != "" {
 		if err := config.SaveAPIServer(apiServer, configPath); err != nil {
 			fmt.Printf("保存 API 服务器配置失败: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 		fmt.Printf("API 服务器已设置为: %s\n", apiServer)
 	}
 
+	// 如果有设置 Azure OpenAI API 版本
+	if apiVersion != "" {
+		if err := config.SaveAPIVersion(apiVersion, configPath); err != nil {
+			fmt.Printf("保存 API 版本配置失败: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		fmt.Printf("API 版本已设置为: %s\n", apiVersion)
+	}
+
 	// 如果有设置模型
 	if model != "" {
 		if err := config.SaveModel(model, configPath); err != nil {
 			fmt.Printf("保存模型配置失败: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 		fmt.Printf("模型已设置为: %s\n", model)
 	}
@@ -107,7 +180,7 @@ func handleConfigFlags() {
 	if key != "" {
 		if err := config.SaveKey(key, configPath); err != nil {
 			fmt.Printf("保存密钥配置失败: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 		fmt.Printf("API 密钥已设置\n")
 	}
@@ -117,27 +190,21 @@ func handleConfigFlags() {
 		// 验证语言参数
 		if language != "zh" && language != "en" {
 			fmt.Printf("不支持的语言: %s (仅支持 zh 或 en)\n", language)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 		if err := config.SaveLanguage(language, configPath); err != nil {
 			fmt.Printf("保存语言配置失败: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 		fmt.Printf("语言已设置为: %s\n", language)
 	}
 }
 
 var reviewCmd = &cobra.Command{
-	Use:   "review [file/directory]",
+	Use:   "review [path...]",
 	Short: "do code review",
-	Args:  cobra.MaximumNArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		var reviewPath string
-		if len(args) > 0 {
-			reviewPath = args[0]
-		} else {
-			reviewPath = "."
-		}
 		configPath := getDefaultConfigPath()
 		if confPath != "" {
 			configPath = confPath
@@ -147,35 +214,181 @@ var reviewCmd = &cobra.Command{
 		baseConf, err := config.LoadFile(configPath)
 		if err != nil {
 			fmt.Printf("load config file failed: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
+		}
+
+		// 命令行指定的路径相对当前目录，默认报告写在仓库根目录
+		outputFile := output
+		if outputFile != "" {
+			if outputFile, err = filepath.Abs(outputFile); err != nil {
+				fmt.Printf("resolve output path failed: %v\n", err)
+				os.Exit(exitFailure)
+			}
 		}
 
 		// 组装引擎配置（仅映射，不改变原有未使用 flag 的行为）
 		engCfg := reviewer.EngineConfig{
-			ReviewPath:    reviewPath,
-			MaxWorkers:    maxPool,       // 先映射，不强制在引擎中使用
-			CommitID:      commitID,      // 映射但暂不生效
-			PromptPath:    promptFile,    // 映射但暂不生效
-			ThinkingChain: thinkingChain, // 映射但暂不生效
-			OutputFile:    "code-review.md",
+			ReviewPaths:   args,
+			MaxWorkers:    maxPool,
+			CommitID:      commitID,
+			BaseRef:       baseRef,
+			HeadRef:       headRef,
+			Staged:        staged,
+			PromptPath:    promptFile,
+			ThinkingChain: thinkingChain,
+			OutputFile:    outputFile,
+			OutputMode:    outputMode,
+			SortBy:        sortBy,
+			FailOn:        failOn,
+			Format:        format,
+			ContextLines:  unified,
+			AdaptivePool:  adaptivePool,
 			Language:      baseConf.Language,
+
+			Includes:        includes,
+			Excludes:        excludes,
+			NoDefaultIgnore: noDefIgnore,
+			RenameThreshold: renameScore,
+			MaxFileSize:     maxFileSize,
+			ChunkTokens:     chunkTokens,
+			Batch:           batch,
+			SummaryPass:     summaryPass,
+			Context:         contextMode,
+
+			Retries:        retries,
+			RequestTimeout: timeout,
+		}
+		if !noCache {
+			engCfg.CacheDir = getDefaultCacheDir()
 		}
 
 		engine := reviewer.NewEngine(context.Background(), engCfg)
 		if err := engine.CreateModel(baseConf); err != nil {
 			fmt.Printf("create model failed: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 		if err := engine.Run(); err != nil {
+			var findings *reviewer.FindingsError
+			if errors.As(err, &findings) {
+				color.Red("✖ %v\n", err)
+				os.Exit(exitFindings)
+			}
 			fmt.Printf("run review failed: %v\n", err)
+			os.Exit(exitFailure)
+		}
+	},
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "manage the review cache",
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "remove cached reviews",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := getDefaultCacheDir()
+		removed, freed, err := reviewer.PruneCache(dir, pruneOlder)
+		if err != nil {
+			fmt.Printf("prune cache failed: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Printf("removed %d cached reviews (%.1f KB) from %s\n", removed, float64(freed)/1024, dir)
 	},
 }
 
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "manage git hooks that review changes before commit or push",
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "install the review hook (pre-commit by default)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sev, err := reviewer.ParseSeverity(hookFailOn)
+		if err != nil {
+			fmt.Printf("invalid --fail-on: %v\n", err)
+			os.Exit(1)
+		}
+		dir := hooksDir()
+		// 记录当前可执行文件的绝对路径，hook 运行时不依赖 PATH；取不到时显式退回 PATH 中的 stellar
+		binary, err := os.Executable()
+		if err != nil {
+			binary = "stellar"
+			fmt.Printf("warning: cannot locate the stellar executable (%v), the hook will run stellar from PATH\n", err)
+		}
+		for _, name := range selectedHooks() {
+			chained, err := hook.Install(dir, name, hook.Options{Binary: binary, FailOn: string(sev)})
+			if err != nil {
+				fmt.Printf("install %s hook failed: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("installed %s hook: %s\n", name, filepath.Join(dir, name))
+			if chained != "" {
+				fmt.Printf("existing hook is kept and runs first: %s\n", chained)
+			}
+		}
+	},
+}
+
+var hookUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "remove the review hook and restore the previous one",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := hooksDir()
+		for _, name := range selectedHooks() {
+			restored, err := hook.Uninstall(dir, name)
+			if errors.Is(err, hook.ErrNotInstalled) {
+				fmt.Printf("%s hook is not installed\n", name)
+				continue
+			}
+			if err != nil {
+				fmt.Printf("uninstall %s hook failed: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("removed %s hook\n", name)
+			if restored {
+				fmt.Printf("restored previous %s hook\n", name)
+			}
+		}
+	},
+}
+
+// hooksDir 当前目录所在仓库的 hook 目录
+func hooksDir() string {
+	dir, err := hook.Dir(".")
+	if err != nil {
+		fmt.Printf("find hooks dir failed: %v\n", err)
+		os.Exit(1)
+	}
+	return dir
+}
+
+// selectedHooks 未指定时默认为 pre-commit
+func selectedHooks() []string {
+	var names []string
+	if hookPreCommit {
+		names = append(names, hook.PreCommit)
+	}
+	if hookPrePush {
+		names = append(names, hook.PrePush)
+	}
+	if len(names) == 0 {
+		names = append(names, hook.PreCommit)
+	}
+	return names
+}
+
 func init() {
 	// 全局 flags (对所有命令生效)
+	rootCmd.PersistentFlags().StringVar(&provider, "set-provider", "", "设置模型服务提供方 (openai/ollama/anthropic/azure)")
 	rootCmd.PersistentFlags().StringVar(&apiServer, "set-apiserver", "", "设置API服务器地址")
+	rootCmd.PersistentFlags().StringVar(&apiVersion, "set-api-version", "", "设置 Azure OpenAI 的 API 版本")
 	rootCmd.PersistentFlags().StringVar(&model, "set-model", "", "设置LLM模型")
 	rootCmd.PersistentFlags().StringVar(&key, "set-key", "", "设置API密钥")
 	rootCmd.PersistentFlags().StringVar(&language, "set-lang", "", "设置语言 (zh/en)")
@@ -183,17 +396,52 @@ func init() {
 
 	// 本地 flags (只对特定命令生效)
 	reviewCmd.Flags().IntVar(&maxPool, "max-pool", 10, "并发操作上限")
-	reviewCmd.Flags().StringVar(&commitID, "commit-id", "", "指定commit ID")
-	reviewCmd.Flags().StringVar(&promptFile, "prompt-file", "", "自定义prompt文件路径")
-	reviewCmd.Flags().BoolVar(&thinkingChain, "thinking-chain", false, "输出模型思考链")
+	reviewCmd.Flags().BoolVar(&adaptivePool, "adaptive-pool", false, "遇到限流或服务端错误时自动收缩并发，成功后逐步恢复")
+	reviewCmd.Flags().IntVar(&retries, "retries", reviewer.DefaultRetries, "超时、限流、5xx 与网络错误的重试次数（指数退避），0 表示不重试")
+	reviewCmd.Flags().DurationVar(&timeout, "timeout", reviewer.DefaultRequestTimeout, "单次模型请求的超时，0 表示不限制")
+	reviewCmd.Flags().StringVar(&commitID, "commit-id", "", "审查指定 commit 相对其父提交的变更")
+	reviewCmd.Flags().StringVar(&baseRef, "base", "", "审查区间的起点 ref，与 --head 的 merge base 对比；也支持 A..B / A...B 语法")
+	reviewCmd.Flags().StringVar(&headRef, "head", "", "审查区间的终点 ref（默认 HEAD）")
+	reviewCmd.Flags().BoolVar(&staged, "staged", false, "只审查暂存区相对 HEAD 的变更（即将提交的内容），pre-commit hook 使用")
+	reviewCmd.MarkFlagsMutuallyExclusive("commit-id", "base", "staged")
+	reviewCmd.Flags().IntVarP(&unified, "unified", "U", 3, "diff 上下文行数")
+	reviewCmd.Flags().StringVar(&format, "format", reviewer.FormatMarkdown, "报告格式 (markdown/sarif)")
+	reviewCmd.Flags().StringVarP(&output, "output", "o", "", "报告文件路径（默认为仓库根目录下的 code-review.md 或 code-review.sarif）")
+	reviewCmd.Flags().StringVar(&failOn, "fail-on", "", "存在达到该等级的问题时以退出码 1 结束 (critical/major/minor/info)，用于 CI 阻止合入；运行失败的退出码为 2")
+	reviewCmd.Flags().StringVar(&sortBy, "sort", reviewer.SortByPath, "报告中文件的顺序 (path/severity)：按路径，或按最严重问题的等级")
+	reviewCmd.Flags().StringVar(&outputMode, "output-mode", reviewer.OutputOverwrite, "报告写入方式 (overwrite/append/timestamped)：覆盖、追加到已有报告、每次运行写入带时间戳的新文件")
+	reviewCmd.Flags().StringSliceVar(&includes, "include", nil, "强制审查匹配的文件（gitignore 语法，可重复）")
+	reviewCmd.Flags().StringSliceVar(&excludes, "exclude", nil, "忽略匹配的文件（gitignore 语法，可重复）")
+	reviewCmd.Flags().BoolVar(&noDefIgnore, "no-default-ignore", false, "关闭默认忽略规则（锁文件、vendor、生成代码等）")
+	reviewCmd.Flags().IntVar(&renameScore, "rename-threshold", 50, "重命名识别的相似度阈值 (0-100)，0 表示关闭")
+	reviewCmd.Flags().StringVar(&contextMode, "context", reviewer.ContextFunction, "diff 之外附带的上下文 (full/function/none)：完整文件、hunk 所在的函数或类型定义、不附带")
+	reviewCmd.Flags().IntVar(&maxFileSize, "max-file-size", 256, "单个文件大小上限（KB），超出时跳过并在报告中记录，0 表示不限制")
+	reviewCmd.Flags().IntVar(&chunkTokens, "chunk-tokens", reviewer.DefaultChunkTokens, "单次审查的变更 token 预算，超出时按 hunk 拆分审查，0 表示不拆分")
+	reviewCmd.Flags().BoolVar(&batch, "batch", false, "将多个小文件的变更合并到一次请求中审查（预算同 --chunk-tokens）")
+	reviewCmd.Flags().BoolVar(&summaryPass, "summary", false, "逐文件审查完成后生成变更集总览（整体风险、跨文件问题、缺失测试、提交说明），置于报告开头")
+	reviewCmd.Flags().StringVar(&promptFile, "prompt-file", "", "自定义 prompt 模板文件路径（Go text/template）")
+	reviewCmd.Flags().BoolVar(&thinkingChain, "thinking-chain", false, "输出推理模型的思考过程（终端实时输出并写入报告）")
+	reviewCmd.Flags().BoolVar(&noCache, "no-cache", false, "不读取也不写入审查结果缓存（~/.stellarspec/cache）")
+
+	cachePruneCmd.Flags().DurationVar(&pruneOlder, "older-than", 30*24*time.Hour, "只删除超过该时长未使用的条目，0 表示清空")
+	cacheCmd.AddCommand(cachePruneCmd)
+
+	for _, c := range []*cobra.Command{hookInstallCmd, hookUninstallCmd} {
+		c.Flags().BoolVar(&hookPreCommit, "pre-commit", false, "pre-commit hook：提交前审查暂存区（未指定时的默认值）")
+		c.Flags().BoolVar(&hookPrePush, "pre-push", false, "pre-push hook：推送前审查相对远端的变更")
+	}
+	hookInstallCmd.Flags().StringVar(&hookFailOn, "fail-on", "major", "存在达到该等级的问题时阻止提交或推送 (critical/major/minor/info)")
+	hookCmd.AddCommand(hookInstallCmd, hookUninstallCmd)
 
 	// 添加子命令
 	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(hookCmd)
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitFailure)
 	}
 }
@@ -0,0 +1,279 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// 支持安装的 hook
+const (
+	PreCommit = "pre-commit"
+	PrePush   = "pre-push"
+)
+
+// 脚本中的标记，用于识别由 stellarspec 安装的 hook
+const marker = "# managed by stellarspec"
+
+// 安装前已存在的 hook 重命名为 <name>.pre-stellar，由新脚本先行调用
+const chainedSuffix = ".pre-stellar"
+
+// ErrNotInstalled hook 不存在
+var ErrNotInstalled = errors.New("hook is not installed")
+
+// Options 写入脚本的参数
+type Options struct {
+	Binary string // stellar 可执行文件路径，不存在时退回 PATH 中的 stellar
+	FailOn string // 阻止提交 / 推送的最低问题等级
+}
+
+// Dir 返回 path 所在仓库的 hook 目录：优先使用 core.hooksPath（相对路径以工作区根目录为基准），未设置时为 $GIT_DIR/hooks
+func Dir(path string) (string, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to open repo: path=%s, err=%v", path, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get work tree: %v", err)
+	}
+	root := worktree.Filesystem.Root()
+
+	// 合并 system / global / local 配置，core.hooksPath 常设置在全局配置中
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return "", fmt.Errorf("failed to read git config: %v", err)
+	}
+	if hooksPath := cfg.Raw.Section("core").Option("hooksPath"); hooksPath != "" {
+		if rest, ok := strings.CutPrefix(hooksPath, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to expand core.hooksPath: %v", err)
+			}
+			hooksPath = filepath.Join(home, rest)
+		}
+		if !filepath.IsAbs(hooksPath) {
+			hooksPath = filepath.Join(root, hooksPath)
+		}
+		return hooksPath, nil
+	}
+
+	gitDir, err := commonGitDir(root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDir, "hooks"), nil
+}
+
+// commonGitDir 解析工作区的 .git：普通仓库为目录；子模块与 git worktree 为指向实际目录的文件，
+// 其中 worktree 的 hook 位于 commondir 指向的主仓库目录
+func commonGitDir(root string) (string, error) {
+	gitDir := filepath.Join(root, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to find git dir: %v", err)
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read .git file: %v", err)
+		}
+		dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+		if !ok {
+			return "", fmt.Errorf("invalid .git file: %s", gitDir)
+		}
+		gitDir = strings.TrimSpace(dir)
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(root, gitDir)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		gitDir = common
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// Install 写入 hook 脚本；已有的非 stellarspec hook 重命名后由新脚本先行调用，返回被串联的旧 hook 路径
+//
+// 重复安装只更新脚本内容，保留已串联的旧 hook
+func Install(dir, name string, opts Options) (string, error) {
+	script, err := render(name, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create hooks dir: %v", err)
+	}
+
+	path := filepath.Join(dir, name)
+	chained := path + chainedSuffix
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil && !managed(data):
+		if _, err := os.Stat(chained); err == nil {
+			return "", fmt.Errorf("%s already exists, refusing to overwrite it with %s", chained, path)
+		}
+		if err := os.Rename(path, chained); err != nil {
+			return "", fmt.Errorf("failed to preserve existing hook: %v", err)
+		}
+	case err != nil && !os.IsNotExist(err):
+		return "", fmt.Errorf("failed to read existing hook: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return "", fmt.Errorf("failed to write hook: %v", err)
+	}
+	// 覆盖已有文件时 WriteFile 不修改权限
+	if err := os.Chmod(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to make hook executable: %v", err)
+	}
+	if _, err := os.Stat(chained); err != nil {
+		return "", nil
+	}
+	return chained, nil
+}
+
+// Uninstall 删除 stellarspec 安装的 hook，并恢复安装时串联的旧 hook；返回是否恢复了旧 hook
+func Uninstall(dir, name string) (bool, error) {
+	path := filepath.Join(dir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, ErrNotInstalled
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read hook: %v", err)
+	}
+	if !managed(data) {
+		return false, fmt.Errorf("%s was not installed by stellarspec, leave it untouched", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("failed to remove hook: %v", err)
+	}
+
+	chained := path + chainedSuffix
+	if _, err := os.Stat(chained); err != nil {
+		return false, nil
+	}
+	if err := os.Rename(chained, path); err != nil {
+		return false, fmt.Errorf("failed to restore previous hook: %v", err)
+	}
+	return true, nil
+}
+
+func managed(script []byte) bool {
+	return strings.Contains(string(script), marker)
+}
+
+// render 生成 hook 脚本
+func render(name string, opts Options) (string, error) {
+	var body string
+	switch name {
+	case PreCommit:
+		body = preCommitScript
+	case PrePush:
+		body = prePushScript
+	default:
+		return "", fmt.Errorf("unsupported hook: %s (expect %s or %s)", name, PreCommit, PrePush)
+	}
+	binary := opts.Binary
+	if binary == "" {
+		binary = "stellar"
+	}
+	return strings.NewReplacer(
+		"{{MARKER}}", marker,
+		"{{NAME}}", name,
+		"{{CHAINED}}", name+chainedSuffix,
+		"{{STELLAR}}", shellQuote(binary),
+		"{{FAIL_ON}}", opts.FailOn,
+	).Replace(body), nil
+}
+
+// shellQuote 单引号包裹，内部的单引号以「结束引号、转义、重新开始引号」的方式写入
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// 两个脚本共用的开头：串联旧 hook 并查找 stellar；stellar 不存在时不阻止操作
+const scriptHeader = `#!/bin/sh
+{{MARKER}}: {{NAME}} hook
+# Remove with: stellar hook uninstall --{{NAME}}
+# Skip once with --no-verify.
+
+hook_dir=$(dirname "$0")
+`
+
+const findStellar = `
+stellar={{STELLAR}}
+if ! command -v "$stellar" >/dev/null 2>&1; then
+	stellar=stellar
+fi
+if ! command -v "$stellar" >/dev/null 2>&1; then
+	echo "stellarspec: stellar not found, skip review" >&2
+	exit 0
+fi
+`
+
+// pre-commit：审查暂存区，存在达到等级的问题（退出码 1）时阻止提交，其他失败只给出提示
+const preCommitScript = scriptHeader + `
+if [ -x "$hook_dir/{{CHAINED}}" ]; then
+	"$hook_dir/{{CHAINED}}" "$@" || exit $?
+fi
+` + findStellar + `
+"$stellar" review --staged --fail-on {{FAIL_ON}}
+status=$?
+if [ $status -eq 1 ]; then
+	echo "stellarspec: commit blocked by findings at or above {{FAIL_ON}}, see the review report" >&2
+	exit 1
+fi
+if [ $status -ne 0 ]; then
+	echo "stellarspec: review did not complete (exit $status), commit not blocked" >&2
+fi
+exit 0
+`
+
+// pre-push：逐个推送的 ref 审查相对远端的变更（PR 视角），新分支对比远端的默认分支；各 ref 的结果写入同一份报告
+const prePushScript = scriptHeader + `
+# git passes the pushed refs on stdin, keep them for the chained hook
+input=$(cat)
+if [ -x "$hook_dir/{{CHAINED}}" ]; then
+	printf '%s\n' "$input" | "$hook_dir/{{CHAINED}}" "$@" || exit $?
+fi
+` + findStellar + `
+blocked=0
+# all refs of one push go into one report: the first replaces the previous report, the rest append
+mode=overwrite
+while read -r local_ref local_sha remote_ref remote_sha; do
+	[ -n "$local_sha" ] || continue
+	# deleting a remote branch
+	case "$local_sha" in *[!0]*) ;; *) continue ;; esac
+	case "$remote_sha" in
+	*[!0]*) base=$remote_sha ;;
+	*) base="$1/HEAD" ;;
+	esac
+	if ! git rev-parse -q --verify "$base^{commit}" >/dev/null; then
+		echo "stellarspec: $base not found locally, skip review of $local_ref" >&2
+		continue
+	fi
+	"$stellar" review --base "$base" --head "$local_sha" --fail-on {{FAIL_ON}} --output-mode "$mode"
+	status=$?
+	mode=append
+	if [ $status -eq 1 ]; then
+		echo "stellarspec: $local_ref has findings at or above {{FAIL_ON}}, see the review report" >&2
+		blocked=1
+	elif [ $status -ne 0 ]; then
+		echo "stellarspec: review of $local_ref did not complete (exit $status), push not blocked" >&2
+	fi
+done <<REFS
+$input
+REFS
+exit $blocked
+`
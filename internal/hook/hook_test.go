@@ -0,0 +1,135 @@
+package hook
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallUninstall(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hooks")
+	chained, err := Install(dir, PreCommit, Options{Binary: "/opt/stellar", FailOn: "major"})
+	if err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if chained != "" {
+		t.Errorf("Install() chained = %q, want none", chained)
+	}
+
+	path := filepath.Join(dir, PreCommit)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("hook not written: %v", err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("hook mode = %v, want executable", info.Mode())
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{marker, "stellar='/opt/stellar'", "--staged --fail-on major"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("hook script lacks %q", want)
+		}
+	}
+
+	restored, err := Uninstall(dir, PreCommit)
+	if err != nil || restored {
+		t.Fatalf("Uninstall() = %v, %v, want false, nil", restored, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("hook still exists after Uninstall: %v", err)
+	}
+	if _, err := Uninstall(dir, PreCommit); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("second Uninstall() error = %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestInstallChainsExistingHook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, PrePush)
+	previous := "#!/bin/sh\necho previous\n"
+	if err := os.WriteFile(path, []byte(previous), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	chained, err := Install(dir, PrePush, Options{FailOn: "critical"})
+	if err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if want := path + chainedSuffix; chained != want {
+		t.Errorf("Install() chained = %q, want %q", chained, want)
+	}
+	if data, _ := os.ReadFile(chained); string(data) != previous {
+		t.Errorf("chained hook = %q, want %q", data, previous)
+	}
+
+	// 重复安装只更新脚本，保留已串联的旧 hook
+	chained, err = Install(dir, PrePush, Options{FailOn: "minor"})
+	if err != nil {
+		t.Fatalf("reinstall error: %v", err)
+	}
+	if want := path + chainedSuffix; chained != want {
+		t.Errorf("reinstall chained = %q, want %q", chained, want)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "--fail-on minor") {
+		t.Errorf("reinstall did not update the script")
+	}
+
+	restored, err := Uninstall(dir, PrePush)
+	if err != nil || !restored {
+		t.Fatalf("Uninstall() = %v, %v, want true, nil", restored, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != previous {
+		t.Errorf("restored hook = %q, want %q", data, previous)
+	}
+	if _, err := os.Stat(path + chainedSuffix); !os.IsNotExist(err) {
+		t.Errorf("chained hook left behind: %v", err)
+	}
+}
+
+func TestInstallRefusesToOverwriteChainedHook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, PreCommit)
+	for _, p := range []string{path, path + chainedSuffix} {
+		if err := os.WriteFile(p, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Install(dir, PreCommit, Options{FailOn: "major"}); err == nil {
+		t.Error("Install() should refuse when the chained hook already exists")
+	}
+}
+
+func TestUninstallLeavesForeignHook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, PreCommit)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho mine\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Uninstall(dir, PreCommit); err == nil {
+		t.Error("Uninstall() should refuse a hook not installed by stellarspec")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("foreign hook removed: %v", err)
+	}
+}
+
+func TestInstallUnsupportedHook(t *testing.T) {
+	if _, err := Install(t.TempDir(), "post-merge", Options{}); err == nil {
+		t.Error("Install() should reject unsupported hooks")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"stellar":       "'stellar'",
+		"/a b/stellar":  "'/a b/stellar'",
+		"/it's/stellar": `'/it'\''s/stellar'`,
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
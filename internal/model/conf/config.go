@@ -3,15 +3,21 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/go-ini/ini"
 )
 
 type BaseConfig struct {
-	APIServer string
-	Model     string
-	Key       string
-	Language  string
+	Provider   string // openai（默认）/ ollama / anthropic / azure
+	APIServer  string
+	Model      string
+	Key        string
+	APIVersion string // 仅 Azure OpenAI 使用
+	Language   string
+
+	// 主模型失败时依次尝试的回退模型，来自 [fallback*] 段
+	Fallbacks []BaseConfig
 }
 
 func LoadFile(path string) (*BaseConfig, error) {
@@ -21,6 +27,8 @@ func LoadFile(path string) (*BaseConfig, error) {
 	}
 	config := &BaseConfig{}
 	// 读取配置值
+	config.Provider = cfg.Section("").Key("Provider").String()
+	config.APIVersion = cfg.Section("").Key("APIVersion").String()
 	config.APIServer = cfg.Section("").Key("APIServer").String()
 	config.Model = cfg.Section("").Key("Model").String()
 	config.Key = cfg.Section("").Key("Key").String()
@@ -31,10 +39,49 @@ func LoadFile(path string) (*BaseConfig, error) {
 		config.Language = "zh"
 	}
 
+	config.Fallbacks = loadFallbacks(cfg, config)
+
 	return config, nil
 
 }
 
+// loadFallbacks 按出现顺序读取名称以 fallback 开头的段（如 [fallback.1]、[fallback.ollama]）
+//
+// 未设置 APIServer、Provider 为空或与主配置相同的段视为同一服务的其他模型，沿用主配置的 APIServer、Key 与 APIVersion；
+// 其他段只使用自身的配置，避免把主服务的密钥发送到其他地址
+func loadFallbacks(cfg *ini.File, primary *BaseConfig) []BaseConfig {
+	var fallbacks []BaseConfig
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(strings.ToLower(section.Name()), "fallback") {
+			continue
+		}
+		fallback := BaseConfig{
+			Provider:   section.Key("Provider").String(),
+			APIServer:  section.Key("APIServer").String(),
+			Model:      section.Key("Model").String(),
+			Key:        section.Key("Key").String(),
+			APIVersion: section.Key("APIVersion").String(),
+			Language:   primary.Language,
+		}
+		sameService := fallback.APIServer == "" && (fallback.Provider == "" || strings.EqualFold(fallback.Provider, primary.Provider))
+		if sameService {
+			fallback.Provider = primary.Provider
+			fallback.APIServer = primary.APIServer
+			if fallback.Key == "" {
+				fallback.Key = primary.Key
+			}
+			if fallback.APIVersion == "" {
+				fallback.APIVersion = primary.APIVersion
+			}
+		}
+		if fallback.Model == "" {
+			fallback.Model = primary.Model
+		}
+		fallbacks = append(fallbacks, fallback)
+	}
+	return fallbacks
+}
+
 func ensureConfigFile(path string) error {
 	// 检查文件是否存在
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -118,3 +165,39 @@ func SaveLanguage(language string, path string) error {
 
 	return nil
 }
+
+func SaveProvider(provider string, path string) error {
+	if err := ensureConfigFile(path); err != nil {
+		return fmt.Errorf("ensure config path failed: err= %v", err)
+	}
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return fmt.Errorf("load config file failed: err= %v", err)
+	}
+
+	cfg.Section("").Key("Provider").SetValue(provider)
+
+	if err := cfg.SaveTo(path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func SaveAPIVersion(apiVersion string, path string) error {
+	if err := ensureConfigFile(path); err != nil {
+		return fmt.Errorf("ensure config path failed: err= %v", err)
+	}
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return fmt.Errorf("load config file failed: err= %v", err)
+	}
+
+	cfg.Section("").Key("APIVersion").SetValue(apiVersion)
+
+	if err := cfg.SaveTo(path); err != nil {
+		return err
+	}
+
+	return nil
+}
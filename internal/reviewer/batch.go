@@ -0,0 +1,224 @@
+package reviewer
+
+import (
+    "fmt"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/fatih/color"
+)
+
+const (
+    // 变更估算不超过预算的该比例时视为小文件，可与其他文件合并审查
+    batchSmallRatio = 4
+    // 单个批次最多包含的文件数
+    maxBatchFiles = 20
+    // 批量审查的变更类型，仅出现在 --prompt-file 模板的 {{.ChangeType}} 中
+    changeBatch = "batch"
+)
+
+// planBatches 将小文件按路径排序后贪心地装入 token 预算内的批次，同目录的文件尽量落在同一批次中
+//
+// 删除文件使用单独的 prompt，需要拆分的大文件单独审查，二者都不参与合并
+func (e *Engine) planBatches(diffs []gitDiff) (batches [][]gitDiff, singles []gitDiff) {
+    budget := e.cfg.ChunkTokens
+    if budget <= 0 {
+        budget = DefaultChunkTokens
+    }
+
+    var small []gitDiff
+    for _, d := range diffs {
+        if d.ChangeType == changeDeleted || estimateTokens(d.Content) > budget/batchSmallRatio {
+            singles = append(singles, d)
+            continue
+        }
+        small = append(small, d)
+    }
+    sort.Slice(small, func(i, j int) bool { return small[i].FilePath < small[j].FilePath })
+
+    var cur []gitDiff
+    tokens := 0
+    flush := func() {
+        switch len(cur) {
+        case 0:
+        case 1:
+            singles = append(singles, cur[0])
+        default:
+            batches = append(batches, cur)
+        }
+        cur, tokens = nil, 0
+    }
+    for _, d := range small {
+        t := estimateTokens(e.batchSection(d))
+        if len(cur) > 0 && (tokens+t > budget || len(cur) >= maxBatchFiles) {
+            flush()
+        }
+        cur = append(cur, d)
+        tokens += t
+    }
+    flush()
+    return batches, singles
+}
+
+// batchDiff 将多个文件的变更合并为一次审查请求
+func (e *Engine) batchDiff(files []gitDiff) gitDiff {
+    var sb strings.Builder
+    for _, f := range files {
+        sb.WriteString(e.batchSection(f))
+    }
+    return gitDiff{FilePath: strings.Join(batchPaths(files), ", "), ChangeType: changeBatch, Content: sb.String(), Files: files}
+}
+
+func batchPaths(files []gitDiff) []string {
+    paths := make([]string, len(files))
+    for i, f := range files {
+        paths[i] = f.FilePath
+    }
+    return paths
+}
+
+// batchSection 批次中单个文件的内容（含 --context 上下文），以带路径的分隔行开始
+func (e *Engine) batchSection(d gitDiff) string {
+    title := fmt.Sprintf("=== %s (%s) ===", d.FilePath, d.ChangeType)
+    if d.OldPath != "" {
+        title = fmt.Sprintf("=== %s (%s from %s) ===", d.FilePath, d.ChangeType, d.OldPath)
+    }
+    return title + "\n" + strings.TrimRight(d.Content+e.fileContext(d)+e.symbolContext(d), "\n") + "\n\n"
+}
+
+// batchName 终端输出中批次的名称
+func batchName(files []gitDiff) string {
+    return fmt.Sprintf("batch[%s]", strings.Join(batchPaths(files), ", "))
+}
+
+// batchDistinct 批次中出现的扩展名或语言（去重后以 / 连接），用于 prompt 中的语言描述
+func batchDistinct(files []gitDiff, of func(path string) string) string {
+    seen := map[string]bool{}
+    var values []string
+    for _, f := range files {
+        v := of(f.FilePath)
+        if v != "" && !seen[v] {
+            seen[v] = true
+            values = append(values, v)
+        }
+    }
+    return strings.Join(values, "/")
+}
+
+// reviewBatch 合并审查一个批次并按文件拆分结果写入报告；模型未按结构化格式输出时退回逐个文件审查
+func (e *Engine) reviewBatch(pool *workerPool, files []gitDiff) error {
+    review, err := e.reviewWithPool(pool, e.batchDiff(files))
+    if err != nil {
+        return err
+    }
+    if !review.structured() {
+        color.Yellow("↻ unstructured batch output, review separately: %s\n", batchName(files))
+        var wg sync.WaitGroup
+        for _, f := range files {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                if err := e.reviewFile(pool, f); err != nil {
+                    e.recordFailed(f, err)
+                }
+            }()
+        }
+        wg.Wait()
+        return nil
+    }
+
+    for _, r := range splitBatchReview(review, files) {
+        if err := e.saveReview(r); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// splitBatchReview 按 file 字段将批次结果拆回各个文件；无法对应到批次中文件的问题归入第一个文件
+func splitBatchReview(review *fileReview, files []gitDiff) []*fileReview {
+    reviews := make([]*fileReview, len(files))
+    index := map[string]int{}
+    for i, f := range files {
+        reviews[i] = &fileReview{FilePath: f.FilePath, OldPath: f.OldPath, Summary: review.fileSummaries[f.FilePath], Model: review.Model}
+        index[f.FilePath] = i
+    }
+    // 思考过程针对整个批次，只附在第一个文件上
+    reviews[0].Reasoning = review.Reasoning
+
+    for _, finding := range review.Findings {
+        i, ok := index[strings.TrimPrefix(finding.File, "./")]
+        if !ok {
+            i, ok = index[normalizeFindingPath(finding.File)]
+        }
+        if !ok {
+            i = matchBySuffix(finding.File, files)
+        }
+        finding.File = files[i].FilePath
+        reviews[i].Findings = append(reviews[i].Findings, finding)
+    }
+    return reviews
+}
+
+// normalizeFindingPath 去掉模型常带上的 ./ 以及 diff 中的 a/、b/ 前缀
+func normalizeFindingPath(p string) string {
+    p = filepath.ToSlash(strings.TrimSpace(p))
+    p = strings.TrimPrefix(p, "./")
+    if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+        return p[2:]
+    }
+    return p
+}
+
+// resolveFindingPaths 单文件审查中模型回显的路径（如 diff 头中的 b/sub/a.go、不完整的路径或重命名前的路径）归一为审查的文件
+//
+// 符号上下文与删除文件的残留引用会列出仓库中其他文件的位置，指向 prompt 中引用过的文件的问题保留原路径；
+// 删除文件的审查本身就是检查其他位置的残留引用，同样保留
+func resolveFindingPaths(findings []Finding, d gitDiff, prompt string) {
+    for i := range findings {
+        f := &findings[i]
+        if f.File == d.FilePath {
+            continue
+        }
+        p := normalizeFindingPath(f.File)
+        switch {
+        case p == "":
+            f.File = d.FilePath
+        case p == d.FilePath || p == d.OldPath,
+            strings.HasSuffix(d.FilePath, "/"+p) || strings.HasSuffix(p, "/"+d.FilePath):
+            f.File = d.FilePath
+        case d.ChangeType == changeDeleted || citesPath(prompt, p):
+            f.File = p
+        default:
+            f.File = d.FilePath
+        }
+    }
+}
+
+// citesPath prompt 中是否以 "路径:行号" 的形式列出了 p
+func citesPath(prompt, p string) bool {
+    for i := 0; ; {
+        j := strings.Index(prompt[i:], p+":")
+        if j < 0 {
+            return false
+        }
+        j += i
+        if j == 0 || strings.ContainsRune(" \t\n(", rune(prompt[j-1])) {
+            return true
+        }
+        i = j + 1
+    }
+}
+
+// matchBySuffix 路径不完整时按后缀匹配批次中的文件，找不到时返回 0
+func matchBySuffix(p string, files []gitDiff) int {
+    p = normalizeFindingPath(p)
+    for i, f := range files {
+        if p != "" && (strings.HasSuffix(f.FilePath, "/"+p) || strings.HasSuffix(p, "/"+f.FilePath)) {
+            return i
+        }
+    }
+    return 0
+}
@@ -0,0 +1,55 @@
+package reviewer
+
+import "testing"
+
+func TestResolveFindingPaths(t *testing.T) {
+    modified := gitDiff{FilePath: "internal/sub/a.go", ChangeType: changeModified}
+    renamed := gitDiff{FilePath: "pkg/new.go", OldPath: "pkg/old.go", ChangeType: changeRenamed}
+    deleted := gitDiff{FilePath: "pkg/gone.go", ChangeType: changeDeleted}
+    // 符号上下文中列出的调用方
+    prompt := "--- a/internal/sub/a.go\n+++ b/internal/sub/a.go\n...\n* func A()\n  -> func A(ctx context.Context)\n  cmd/main.go:12: sub.A()\n"
+    tests := []struct {
+        name string
+        d    gitDiff
+        file string
+        want string
+    }{
+        {"exact", modified, "internal/sub/a.go", "internal/sub/a.go"},
+        {"diff prefix", modified, "b/internal/sub/a.go", "internal/sub/a.go"},
+        {"dot slash", modified, "./internal/sub/a.go", "internal/sub/a.go"},
+        {"suffix", modified, "sub/a.go", "internal/sub/a.go"},
+        {"empty", modified, "", "internal/sub/a.go"},
+        {"cited caller", modified, "cmd/main.go", "cmd/main.go"},
+        {"cited caller with prefix", modified, "b/cmd/main.go", "cmd/main.go"},
+        {"uncited path", modified, "cmd/other.go", "internal/sub/a.go"},
+        {"partial match of cited path", modified, "main.go", "internal/sub/a.go"},
+        {"old path", renamed, "a/pkg/old.go", "pkg/new.go"},
+        {"deleted self", deleted, "a/pkg/gone.go", "pkg/gone.go"},
+        {"deleted reference", deleted, "./cmd/main.go", "cmd/main.go"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            findings := []Finding{{File: tt.file}}
+            resolveFindingPaths(findings, tt.d, prompt)
+            if findings[0].File != tt.want {
+                t.Errorf("resolveFindingPaths(%q) = %q, want %q", tt.file, findings[0].File, tt.want)
+            }
+        })
+    }
+}
+
+func TestMatchBySuffix(t *testing.T) {
+    files := []gitDiff{{FilePath: "a.go"}, {FilePath: "internal/b.go"}, {FilePath: "cmd/c.go"}}
+    tests := map[string]int{
+        "b/b.go":        1,
+        "c.go":          2,
+        "repo/cmd/c.go": 2,
+        "unknown.go":    0,
+        "":              0,
+    }
+    for p, want := range tests {
+        if got := matchBySuffix(p, files); got != want {
+            t.Errorf("matchBySuffix(%q) = %d, want %d", p, got, want)
+        }
+    }
+}
@@ -0,0 +1,143 @@
+package reviewer
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/fatih/color"
+)
+
+// 缓存格式变化时递增，使旧条目失效
+const cacheVersion = "1"
+
+// reviewCache 以 prompt、模型与语言的哈希为键缓存结构化审查结果，nil 表示不使用缓存
+type reviewCache struct {
+    dir string
+}
+
+// cacheEntry 缓存文件的内容
+type cacheEntry struct {
+    Summary       string            `json:"summary"`
+    Findings      []Finding         `json:"findings"`
+    Reasoning     string            `json:"reasoning,omitempty"`
+    Model         string            `json:"model,omitempty"`
+    FileSummaries map[string]string `json:"file_summaries,omitempty"`
+    CreatedAt     time.Time         `json:"created_at"`
+}
+
+// cacheKey 渲染后的 prompt 已包含 diff、上下文与 prompt 模板，再加上模型回退链、语言与是否记录思考过程
+//
+// 未开启 --thinking-chain 时缓存的结果没有思考过程，不能用于开启后的运行
+func (e *Engine) cacheKey(systemPrompt, userQuery string) string {
+    names := make([]string, 0, len(e.models))
+    for _, m := range e.models {
+        names = append(names, m.name)
+    }
+    h := sha256.New()
+    for _, part := range []string{cacheVersion, strings.Join(names, ","), e.cfg.Language, strconv.FormatBool(e.cfg.ThinkingChain), systemPrompt, userQuery} {
+        h.Write([]byte(part))
+        h.Write([]byte{0})
+    }
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *reviewCache) path(key string) string {
+    return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get 读取缓存的审查结果，命中时刷新修改时间，供 prune 按最近使用时间清理
+func (c *reviewCache) get(key string, d gitDiff) (*fileReview, bool) {
+    if c == nil {
+        return nil, false
+    }
+    p := c.path(key)
+    data, err := os.ReadFile(p)
+    if err != nil {
+        return nil, false
+    }
+    var entry cacheEntry
+    if err := json.Unmarshal(data, &entry); err != nil {
+        return nil, false
+    }
+    now := time.Now()
+    _ = os.Chtimes(p, now, now)
+    return &fileReview{
+        FilePath:      d.FilePath,
+        OldPath:       d.OldPath,
+        Summary:       entry.Summary,
+        Findings:      entry.Findings,
+        Reasoning:     entry.Reasoning,
+        Model:         entry.Model,
+        fileSummaries: entry.FileSummaries,
+    }, true
+}
+
+// put 写入结构化审查结果；先写临时文件再重命名，避免并发运行读到不完整的条目
+func (c *reviewCache) put(key string, review *fileReview) {
+    if c == nil || !review.structured() {
+        return
+    }
+    data, err := json.Marshal(&cacheEntry{
+        Summary:       review.Summary,
+        Findings:      review.Findings,
+        Reasoning:     review.Reasoning,
+        Model:         review.Model,
+        FileSummaries: review.fileSummaries,
+        CreatedAt:     time.Now(),
+    })
+    if err != nil {
+        return
+    }
+    if err := writeFileAtomic(c.path(key), data); err != nil {
+        color.Red("failed to write cache: err=%v\n", err)
+    }
+}
+
+// PruneCache 删除缓存目录中超过 olderThan 未使用的条目，olderThan 为 0 时清空；返回删除的条目数与释放的字节数
+func PruneCache(dir string, olderThan time.Duration) (int, int64, error) {
+    cutoff := time.Now().Add(-olderThan)
+    removed, freed := 0, int64(0)
+    err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+        if err != nil {
+            if errors.Is(err, fs.ErrNotExist) {
+                return nil
+            }
+            return err
+        }
+        if d.IsDir() {
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return nil
+        }
+        if olderThan > 0 && info.ModTime().After(cutoff) {
+            return nil
+        }
+        if err := os.Remove(p); err != nil {
+            return err
+        }
+        removed++
+        freed += info.Size()
+        return nil
+    })
+    if err != nil {
+        return removed, freed, err
+    }
+    // 清理空的分片目录
+    entries, _ := os.ReadDir(dir)
+    for _, entry := range entries {
+        if entry.IsDir() {
+            _ = os.Remove(filepath.Join(dir, entry.Name()))
+        }
+    }
+    return removed, freed, nil
+}
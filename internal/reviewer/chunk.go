@@ -0,0 +1,274 @@
+package reviewer
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// DefaultChunkTokens 单个审查窗口中变更内容的默认 token 预算
+const DefaultChunkTokens = 8000
+
+// 审查窗口说明中列出的变更区间上限
+const maxOverviewRanges = 40
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)$`)
+
+// estimateTokens 粗略估算 token 数：ASCII 约 4 个字符一个 token，其他字符（如中文）按每个字符一个 token 计
+func estimateTokens(s string) int {
+    ascii, other := 0, 0
+    for _, r := range s {
+        if r < 0x80 {
+            ascii++
+        } else {
+            other++
+        }
+    }
+    return (ascii+3)/4 + other
+}
+
+// chunkDiff 将超过 token 预算的变更按 hunk 边界切分为多个审查窗口
+//
+// 每个窗口都带有文件头（--- / +++ 以及重命名信息）和一段说明：当前是第几部分、全文件的 hunk 列表，
+// 使模型在只看到部分变更时也了解文件整体的改动范围。单个 hunk 超出预算时按行拆分并重算 hunk 头；
+// 新增文件没有 hunk，按行切分并注明行号区间。删除文件的审查内容本身有上限，不做切分
+func (e *Engine) chunkDiff(d gitDiff) []gitDiff {
+    budget := e.cfg.ChunkTokens
+    if budget <= 0 || d.ChangeType == changeDeleted || estimateTokens(d.Content) <= budget {
+        return []gitDiff{d}
+    }
+
+    var parts []string
+    var overview []string
+    if d.ChangeType == changeAdded {
+        parts, overview = splitContent(d.Content, budget)
+    } else {
+        header, hunks := splitHunks(d.Content)
+        for _, h := range hunks {
+            overview = append(overview, firstLine(h))
+        }
+        parts = packHunks(header, hunks, budget-estimateTokens(header))
+    }
+    if len(parts) <= 1 {
+        return []gitDiff{d}
+    }
+
+    chunks := make([]gitDiff, len(parts))
+    for i, p := range parts {
+        c := d
+        c.Chunk, c.Chunks = i+1, len(parts)
+        c.Content = e.chunkPreamble(d.FilePath, i+1, len(parts), overview) + p
+        chunks[i] = c
+    }
+    return chunks
+}
+
+// chunkPreamble 每个审查窗口开头的共享上下文
+func (e *Engine) chunkPreamble(file string, part, total int, overview []string) string {
+    var sb strings.Builder
+    if e.cfg.Language == "en" {
+        fmt.Fprintf(&sb, "[Part %d/%d of the changes to %s. The other parts are reviewed separately; only report problems visible in this part. All changed ranges in this file:\n", part, total, file)
+    } else {
+        fmt.Fprintf(&sb, "[%s 的变更较大，已拆分审查，这是第 %d/%d 部分。其他部分会单独审查，只需报告本部分中可见的问题。该文件全部变更区间：\n", file, part, total)
+    }
+    for i, o := range overview {
+        if i == maxOverviewRanges {
+            fmt.Fprintf(&sb, "... (+%d)\n", len(overview)-i)
+            break
+        }
+        sb.WriteString(o)
+        sb.WriteByte('\n')
+    }
+    sb.WriteString("]\n\n")
+    return sb.String()
+}
+
+// splitHunks 拆分 unified diff 为文件头与各个 hunk
+func splitHunks(diff string) (header string, hunks []string) {
+    lines := strings.SplitAfter(diff, "\n")
+    i := 0
+    for i < len(lines) && !strings.HasPrefix(lines[i], "@@") {
+        i++
+    }
+    header = strings.Join(lines[:i], "")
+
+    var cur strings.Builder
+    for _, l := range lines[i:] {
+        if strings.HasPrefix(l, "@@") && cur.Len() > 0 {
+            hunks = append(hunks, cur.String())
+            cur.Reset()
+        }
+        cur.WriteString(l)
+    }
+    if cur.Len() > 0 {
+        hunks = append(hunks, cur.String())
+    }
+    return header, hunks
+}
+
+// packHunks 贪心地将相邻 hunk 装入预算内的窗口，每个窗口都以文件头开始
+func packHunks(header string, hunks []string, budget int) []string {
+    budget = max(budget, 1)
+    var parts []string
+    var cur strings.Builder
+    curTokens := 0
+    flush := func() {
+        if cur.Len() > 0 {
+            parts = append(parts, header+cur.String())
+            cur.Reset()
+            curTokens = 0
+        }
+    }
+    for _, h := range hunks {
+        pieces := []string{h}
+        if estimateTokens(h) > budget {
+            pieces = splitHunk(h, budget)
+        }
+        for _, p := range pieces {
+            t := estimateTokens(p)
+            if curTokens > 0 && curTokens+t > budget {
+                flush()
+            }
+            cur.WriteString(p)
+            curTokens += t
+        }
+    }
+    flush()
+    return parts
+}
+
+// splitHunk 将超出预算的单个 hunk 按行拆分为多个 hunk，并重新计算每个 hunk 头中的行号
+func splitHunk(hunk string, budget int) []string {
+    lines := strings.SplitAfter(strings.TrimSuffix(hunk, "\n"), "\n")
+    m := hunkHeaderPattern.FindStringSubmatch(strings.TrimSuffix(lines[0], "\n"))
+    if m == nil {
+        return []string{hunk}
+    }
+    oldStart := hunkStart(m[1], m[2])
+    newStart := hunkStart(m[3], m[4])
+    section := m[5]
+
+    var pieces []string
+    var body strings.Builder
+    oldCount, newCount, tokens := 0, 0, 0
+    flush := func() {
+        if body.Len() == 0 {
+            return
+        }
+        head := fmt.Sprintf("@@ -%s +%s @@%s\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), section)
+        pieces = append(pieces, head+body.String())
+        oldStart += oldCount
+        newStart += newCount
+        oldCount, newCount, tokens = 0, 0, 0
+        body.Reset()
+    }
+    for _, l := range lines[1:] {
+        if !strings.HasSuffix(l, "\n") {
+            l += "\n"
+        }
+        t := estimateTokens(l)
+        // "\ No newline at end of file" 必须紧跟在所属行之后
+        if tokens > 0 && tokens+t > budget && !strings.HasPrefix(l, "\\") {
+            flush()
+        }
+        switch {
+        case strings.HasPrefix(l, "+"):
+            newCount++
+        case strings.HasPrefix(l, "-"):
+            oldCount++
+        case strings.HasPrefix(l, "\\"):
+        default:
+            oldCount++
+            newCount++
+        }
+        body.WriteString(l)
+        tokens += t
+    }
+    flush()
+    return pieces
+}
+
+// hunkStart 将 hunk 头中的起始行转换为 0-based 的“之前的行数”，与 hunkRange 的参数一致
+func hunkStart(start, count string) int {
+    n, _ := strconv.Atoi(start)
+    if count == "0" {
+        return n
+    }
+    return n - 1
+}
+
+// splitContent 新增文件按行切分，每部分注明在新文件中的行号区间
+func splitContent(content string, budget int) (parts, overview []string) {
+    lines := strings.SplitAfter(content, "\n")
+    if lines[len(lines)-1] == "" {
+        lines = lines[:len(lines)-1]
+    }
+    var cur strings.Builder
+    start, tokens := 0, 0
+    var ranges [][2]int
+    var bodies []string
+    for i, l := range lines {
+        t := estimateTokens(l)
+        if tokens > 0 && tokens+t > budget {
+            ranges = append(ranges, [2]int{start + 1, i})
+            bodies = append(bodies, cur.String())
+            cur.Reset()
+            start, tokens = i, 0
+        }
+        cur.WriteString(l)
+        tokens += t
+    }
+    if cur.Len() > 0 {
+        ranges = append(ranges, [2]int{start + 1, len(lines)})
+        bodies = append(bodies, cur.String())
+    }
+    for i, r := range ranges {
+        overview = append(overview, fmt.Sprintf("lines %d-%d", r[0], r[1]))
+        parts = append(parts, fmt.Sprintf("(new file, lines %d-%d of %d)\n%s", r[0], r[1], len(lines), bodies[i]))
+    }
+    return parts, overview
+}
+
+// mergeChunkReviews 合并同一文件各部分的审查结果为一个报告段落
+//
+// 结构化结果合并 findings 并按部分拼接 summary；无法结构化的部分以原文附在 summary 中；
+// 审查失败的部分在 summary 中注明，并计入 FailedParts
+func (e *Engine) mergeChunkReviews(d gitDiff, reviews []*fileReview, errs []error) *fileReview {
+    total := len(reviews)
+    merged := &fileReview{FilePath: d.FilePath, OldPath: d.OldPath}
+    var summaries, raws, reasonings, models []string
+    structured := false
+    for i, r := range reviews {
+        label := fmt.Sprintf("[%d/%d] ", i+1, total)
+        if errs[i] != nil {
+            merged.FailedParts++
+            summaries = append(summaries, label+e.failureText(errs[i]))
+            continue
+        }
+        if r.Reasoning != "" {
+            reasonings = append(reasonings, label+r.Reasoning)
+        }
+        if r.Model != "" && !slices.Contains(models, r.Model) {
+            models = append(models, r.Model)
+        }
+        if !r.structured() {
+            raws = append(raws, label+r.Raw)
+            continue
+        }
+        structured = true
+        if r.Summary != "" {
+            summaries = append(summaries, label+r.Summary)
+        }
+        merged.Findings = append(merged.Findings, r.Findings...)
+    }
+    merged.Reasoning = strings.Join(reasonings, "\n\n")
+    merged.Model = strings.Join(models, ", ")
+    if !structured {
+        merged.Raw = strings.Join(append(summaries, raws...), "\n\n")
+        return merged
+    }
+    merged.Summary = strings.Join(append(summaries, raws...), "\n\n")
+    return merged
+}
@@ -0,0 +1,100 @@
+package reviewer
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestSplitHunk(t *testing.T) {
+    tests := []struct {
+        name   string
+        hunk   string
+        budget int
+        want   []string
+    }{
+        {
+            name:   "fits budget",
+            hunk:   "@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+            budget: 100,
+            want:   []string{"@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+        },
+        {
+            name:   "headers recomputed per piece",
+            hunk:   "@@ -10,4 +10,5 @@ func F()\n ctx\n-old1\n+new1\n+new2\n ctx2\n-old2\n+new3\n\\ No newline at end of file\n",
+            budget: 1,
+            want: []string{
+                "@@ -10 +10 @@ func F()\n ctx\n",
+                "@@ -11 +10,0 @@ func F()\n-old1\n",
+                "@@ -11,0 +11 @@ func F()\n+new1\n",
+                "@@ -11,0 +12 @@ func F()\n+new2\n",
+                "@@ -12 +13 @@ func F()\n ctx2\n",
+                "@@ -13 +13,0 @@ func F()\n-old2\n",
+                "@@ -13,0 +14 @@ func F()\n+new3\n\\ No newline at end of file\n",
+            },
+        },
+        {
+            name:   "zero context hunk",
+            hunk:   "@@ -5,0 +6,2 @@\n+x\n+y\n",
+            budget: 1,
+            want: []string{
+                "@@ -5,0 +6 @@\n+x\n",
+                "@@ -5,0 +7 @@\n+y\n",
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := splitHunk(tt.hunk, tt.budget)
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("splitHunk() =\n%q\nwant\n%q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestSplitHunks(t *testing.T) {
+    diff := "--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n@@ -9,0 +10 @@\n+c\n"
+    header, hunks := splitHunks(diff)
+    if header != "--- a/f\n+++ b/f\n" {
+        t.Errorf("header = %q", header)
+    }
+    want := []string{"@@ -1 +1 @@\n-a\n+b\n", "@@ -9,0 +10 @@\n+c\n"}
+    if !reflect.DeepEqual(hunks, want) {
+        t.Errorf("hunks = %q, want %q", hunks, want)
+    }
+}
+
+func TestPackHunks(t *testing.T) {
+    header := "--- a/f\n+++ b/f\n"
+    hunks := []string{
+        "@@ -1 +1 @@\n-a\n+b\n",
+        "@@ -9,0 +10 @@\n+c\n",
+        "@@ -20 +21 @@\n-d\n+e\n",
+    }
+
+    parts := packHunks(header, hunks, 1000)
+    if want := []string{header + strings.Join(hunks, "")}; !reflect.DeepEqual(parts, want) {
+        t.Errorf("packHunks(large budget) = %q, want %q", parts, want)
+    }
+
+    budget := estimateTokens(hunks[0])
+    parts = packHunks(header, hunks, budget)
+    if len(parts) < 2 {
+        t.Fatalf("packHunks(small budget) returned %d parts, want at least 2", len(parts))
+    }
+    var body strings.Builder
+    for i, p := range parts {
+        if !strings.HasPrefix(p, header) {
+            t.Errorf("part %d lacks file header: %q", i, p)
+        }
+        rest := strings.TrimPrefix(p, header)
+        if !strings.HasPrefix(rest, "@@ ") {
+            t.Errorf("part %d does not start with a hunk header: %q", i, p)
+        }
+        body.WriteString(rest)
+    }
+    if got, want := body.String(), strings.Join(hunks, ""); got != want {
+        t.Errorf("packed hunks = %q, want %q", got, want)
+    }
+}
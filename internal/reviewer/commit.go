@@ -0,0 +1,226 @@
+package reviewer
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/fatih/color"
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/object"
+    "github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// commitDiff 收集指定 commit 相对其第一个父提交的变更；根提交则将整棵 tree 视为新增
+func (e *Engine) commitDiff(repo *git.Repository, rev string) ([]gitDiff, error) {
+    commit, err := resolveCommit(repo, rev)
+    if err != nil {
+        return nil, err
+    }
+
+    tree, err := commit.Tree()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get commit tree: commit=%s, err=%v", commit.Hash, err)
+    }
+
+    var parentTree *object.Tree
+    if commit.NumParents() > 0 {
+        parent, err := commit.Parent(0)
+        if err != nil {
+            return nil, fmt.Errorf("failed to get parent commit: commit=%s, err=%v", commit.Hash, err)
+        }
+        parentTree, err = parent.Tree()
+        if err != nil {
+            return nil, fmt.Errorf("failed to get parent tree: commit=%s, err=%v", parent.Hash, err)
+        }
+    }
+
+    e.target = fmt.Sprintf("commit %s %s", commit.Hash.String()[:7], firstLine(commit.Message))
+    color.Cyan("◆ %s\n", e.target)
+    return e.treeDiff(parentTree, tree)
+}
+
+// rangeDiff 收集一个提交区间的变更
+//
+// base 可以是单个 ref，也可以是 A..B / A...B 形式的区间表达式：
+//   - A...B 与 --base A --head B 等价，对比 merge-base(A, B) 与 B，即 PR 视角
+//   - A..B 直接对比 A 与 B 两棵 tree
+//
+// head 为空时默认为 HEAD
+func (e *Engine) rangeDiff(repo *git.Repository, base, head string) ([]gitDiff, error) {
+    useMergeBase := true
+    if left, right, ok := strings.Cut(base, "..."); ok {
+        base, head = left, right
+    } else if left, right, ok := strings.Cut(base, ".."); ok {
+        base, head = left, right
+        useMergeBase = false
+    }
+    if base == "" {
+        base = "HEAD"
+    }
+    if head == "" {
+        head = "HEAD"
+    }
+
+    baseCommit, err := resolveCommit(repo, base)
+    if err != nil {
+        return nil, err
+    }
+    headCommit, err := resolveCommit(repo, head)
+    if err != nil {
+        return nil, err
+    }
+
+    sep := ".."
+    fromCommit := baseCommit
+    if useMergeBase {
+        sep = "..."
+        bases, err := baseCommit.MergeBase(headCommit)
+        if err != nil {
+            return nil, fmt.Errorf("failed to compute merge base: base=%s, head=%s, err=%v", base, head, err)
+        }
+        if len(bases) == 0 {
+            return nil, fmt.Errorf("no merge base found: base=%s, head=%s", base, head)
+        }
+        fromCommit = bases[0]
+    }
+
+    fromTree, err := fromCommit.Tree()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get tree: commit=%s, err=%v", fromCommit.Hash, err)
+    }
+    toTree, err := headCommit.Tree()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get tree: commit=%s, err=%v", headCommit.Hash, err)
+    }
+
+    e.target = fmt.Sprintf("range %s%s%s (%s..%s)", base, sep, head, fromCommit.Hash.String()[:7], headCommit.Hash.String()[:7])
+    color.Cyan("◆ %s\n", e.target)
+    return e.treeDiff(fromTree, toTree)
+}
+
+// resolveCommit 将 sha、分支名、tag 等 revision 解析为 commit 对象
+func resolveCommit(repo *git.Repository, rev string) (*object.Commit, error) {
+    hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+    if err != nil {
+        return nil, fmt.Errorf("failed to resolve revision: rev=%s, err=%v", rev, err)
+    }
+    commit, err := repo.CommitObject(*hash)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get commit: rev=%s, err=%v", rev, err)
+    }
+    return commit, nil
+}
+
+// treeDiff 对比两棵 tree 并生成逐文件的变更，from 为 nil 时 to 中的文件全部视为新增
+func (e *Engine) treeDiff(from, to *object.Tree) ([]gitDiff, error) {
+    opts := &object.DiffTreeOptions{
+        DetectRenames: e.cfg.RenameThreshold > 0,
+        RenameScore:   uint(min(max(e.cfg.RenameThreshold, 1), 100)),
+    }
+    changes, err := object.DiffTreeWithOptions(e.ctx, from, to, opts)
+    if err != nil {
+        return nil, fmt.Errorf("failed to diff tree: %v", err)
+    }
+
+    e.walker = e.treeWalker(to)
+    diffs := []gitDiff{}
+    // 删除的文件最后统一检索残留引用
+    deleted := map[string]string{}
+    for _, change := range changes {
+        action, err := change.Action()
+        if err != nil {
+            color.Red("failed to get change action: err=%v\n", err)
+            continue
+        }
+        fromFile, toFile, err := change.Files()
+        if err != nil {
+            color.Red("failed to get change files: err=%v\n", err)
+            continue
+        }
+        // 子模块（gitlink）没有可审查的内容，change.Files 对其返回 nil
+        if (action != merkletrie.Insert && fromFile == nil) || (action != merkletrie.Delete && toFile == nil) {
+            continue
+        }
+
+        switch action {
+        case merkletrie.Insert:
+            file := change.To.Name
+            if e.skipFile(file) {
+                continue
+            }
+            content, err := toFile.Contents()
+            if err != nil {
+                color.Red("failed to get file content: path=%s, err=%v\n", file, err)
+                continue
+            }
+            if reason := e.unreviewable(file, len(content), content); reason != "" {
+                diffs = append(diffs, skippedDiff(file, "", changeAdded, reason))
+                continue
+            }
+            if e.skipGenerated(file, content) {
+                continue
+            }
+            diffs = append(diffs, gitDiff{FilePath: file, Content: content, ChangeType: changeAdded})
+            color.Yellow("Δ add: %s\n", file)
+        case merkletrie.Modify:
+            file := change.To.Name
+            if e.skipFile(file) {
+                continue
+            }
+            oldContent, newContent, err := changeContents(fromFile, toFile)
+            if err != nil {
+                color.Red("failed to get file content: path=%s, err=%v\n", file, err)
+                continue
+            }
+            // 开启重命名识别时，重命名以 From/To 名称不同的 Modify 出现
+            if change.From.Name != file {
+                if d, ok := e.renameDiff(change.From.Name, file, oldContent, newContent, similarity(oldContent, newContent)); ok {
+                    diffs = append(diffs, d)
+                }
+                continue
+            }
+            if reason := e.unreviewable(file, len(newContent), oldContent, newContent); reason != "" {
+                diffs = append(diffs, skippedDiff(file, "", changeModified, reason))
+                continue
+            }
+            if e.skipGenerated(file, newContent) {
+                continue
+            }
+            diffs = append(diffs, gitDiff{FilePath: file, Content: e.generateProfessionalDiff(file, oldContent, newContent), ChangeType: changeModified, NewContent: newContent, OldContent: oldContent})
+            color.Yellow("Δ mod: %s\n", file)
+        case merkletrie.Delete:
+            file := change.From.Name
+            if e.skipFile(file) {
+                continue
+            }
+            oldContent, err := fromFile.Contents()
+            if err != nil {
+                color.Red("failed to get deleted file content: path=%s, err=%v\n", file, err)
+                continue
+            }
+            deleted[file] = oldContent
+        }
+    }
+    return append(diffs, e.deletedDiffs(deleted, e.walker)...), nil
+}
+
+// changeContents 读取一次变更前后的文件内容
+func changeContents(from, to *object.File) (string, string, error) {
+    oldContent, err := from.Contents()
+    if err != nil {
+        return "", "", err
+    }
+    newContent, err := to.Contents()
+    if err != nil {
+        return "", "", err
+    }
+    return oldContent, newContent, nil
+}
+
+func firstLine(s string) string {
+    if i := strings.IndexByte(s, '\n'); i >= 0 {
+        return s[:i]
+    }
+    return s
+}
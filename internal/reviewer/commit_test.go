@@ -0,0 +1,107 @@
+package reviewer
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/filemode"
+    "github.com/go-git/go-git/v5/plumbing/format/index"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitAll 提交暂存区，edit 在提交前修改暂存区
+func commitAll(t *testing.T, repo *git.Repository, msg string, edit func(idx *index.Index)) {
+    t.Helper()
+    w, err := repo.Worktree()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := w.Add("."); err != nil {
+        t.Fatal(err)
+    }
+    if edit != nil {
+        idx, err := repo.Storer.Index()
+        if err != nil {
+            t.Fatal(err)
+        }
+        edit(idx)
+        if err := repo.Storer.SetIndex(idx); err != nil {
+            t.Fatal(err)
+        }
+    }
+    sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+    if _, err := w.Commit(msg, &git.CommitOptions{Author: sig}); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestCommitDiffSkipsSubmodule(t *testing.T) {
+    root := t.TempDir()
+    repo, err := git.PlainInit(root, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    write := func(name, content string) {
+        if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    write("a.go", "package a\n")
+    commitAll(t, repo, "init", nil)
+
+    // 新增 gitlink 的同时修改普通文件
+    write("a.go", "package a\n\nfunc A() {}\n")
+    commitAll(t, repo, "add submodule", func(idx *index.Index) {
+        idx.Entries = append(idx.Entries, &index.Entry{
+            Name: "sub",
+            Mode: filemode.Submodule,
+            Hash: plumbing.NewHash("1111111111111111111111111111111111111111"),
+        })
+    })
+
+    head := func() *object.Tree {
+        ref, err := repo.Head()
+        if err != nil {
+            t.Fatal(err)
+        }
+        commit, err := repo.CommitObject(ref.Hash())
+        if err != nil {
+            t.Fatal(err)
+        }
+        tree, err := commit.Tree()
+        if err != nil {
+            t.Fatal(err)
+        }
+        return tree
+    }
+    if entry, err := head().FindEntry("sub"); err != nil || entry.Mode != filemode.Submodule {
+        t.Fatalf("gitlink not committed: %v", err)
+    }
+
+    e := NewEngine(context.Background(), EngineConfig{})
+    diffs, err := e.commitDiff(repo, "HEAD")
+    if err != nil {
+        t.Fatalf("commitDiff() error: %v", err)
+    }
+    if len(diffs) != 1 || diffs[0].FilePath != "a.go" || diffs[0].ChangeType != changeModified {
+        t.Errorf("commitDiff() = %+v, want only the modified a.go", diffs)
+    }
+
+    // 工作区中没有 sub，重新暂存时 gitlink 被删除
+    commitAll(t, repo, "remove submodule", nil)
+    if _, err := head().FindEntry("sub"); err == nil {
+        t.Fatal("gitlink not removed")
+    }
+    diffs, err = e.commitDiff(repo, "HEAD")
+    if err != nil {
+        t.Fatalf("commitDiff() error: %v", err)
+    }
+    if len(diffs) != 0 {
+        t.Errorf("commitDiff() = %+v, want no diffs", diffs)
+    }
+}
@@ -0,0 +1,307 @@
+package reviewer
+
+import (
+    "fmt"
+    "go/ast"
+    "go/parser"
+    "go/token"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// --context 的取值
+const (
+    ContextFull     = "full"     // 附带变更后的完整文件，超出 token 预算时退回 function
+    ContextFunction = "function" // 附带每个 hunk 所在的函数 / 类型定义
+    ContextNone     = "none"     // 只发送 diff
+)
+
+// 单个定义附带的行数上限
+const maxContextBlockLines = 150
+
+// 非 Go 文件中函数、类、方法定义的识别规则
+var definitionPatterns = []*regexp.Regexp{
+    keywordDefinitionPattern,
+    // Java / C# / C++ 等带返回类型的方法签名
+    regexp.MustCompile(`^\s*(?:[\w<>\[\],.*&:]+\s+)+\**~?([A-Za-z_]\w*)\s*\([^;]*$`),
+    // JavaScript / TypeScript 中赋值给变量的函数
+    regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`),
+}
+
+// C 系语言中形似方法签名、实为控制语句的行
+var controlKeyword = regexp.MustCompile(`^\s*(?:\}\s*)?(?:if|else|for|while|switch|catch|return|new|throw|do|try)\b`)
+
+// lineRange 新文件中的行号区间（1-based，闭区间）
+type lineRange struct {
+    start, end int
+}
+
+// fileContext 根据 --context 生成附加在 diff 之后的上下文；新增、删除文件以及批次本身不附带
+func (e *Engine) fileContext(d gitDiff) string {
+    if d.NewContent == "" || e.cfg.Context == ContextNone {
+        return ""
+    }
+    lines := strings.Split(strings.TrimSuffix(d.NewContent, "\n"), "\n")
+    budget := e.cfg.ChunkTokens
+    if budget <= 0 {
+        budget = DefaultChunkTokens
+    }
+
+    if e.cfg.Context == ContextFull && estimateTokens(d.NewContent) <= budget {
+        // 完整文件不受单个定义的行数上限约束，大小已由 token 预算限定
+        return e.contextSection(d.FilePath, lines, []lineRange{{1, len(lines)}}, len(lines), budget)
+    }
+    blocks := enclosingBlocks(d.FilePath, d.NewContent, lines, touchedLines(d.Content))
+    if len(blocks) == 0 {
+        return ""
+    }
+    return e.contextSection(d.FilePath, lines, blocks, maxContextBlockLines, budget)
+}
+
+// contextSection 带行号输出各个区间，每个区间最多 blockLines 行，超过预算的区间不再附带
+func (e *Engine) contextSection(file string, lines []string, blocks []lineRange, blockLines, budget int) string {
+    var sb strings.Builder
+    if e.cfg.Language == "en" {
+        fmt.Fprintf(&sb, "\n\nSurrounding context of %s (new file, for reference only; review the diff above):\n", file)
+    } else {
+        fmt.Fprintf(&sb, "\n\n%s 的上下文（变更后的文件，仅供参考，请审查上面的 diff）：\n", file)
+    }
+    tokens := 0
+    for _, b := range blocks {
+        var block strings.Builder
+        end := min(b.end, len(lines), b.start+blockLines-1)
+        fmt.Fprintf(&block, "--- lines %d-%d ---\n", b.start, b.end)
+        for n := b.start; n <= end; n++ {
+            fmt.Fprintf(&block, "%5d | %s\n", n, lines[n-1])
+        }
+        if end < b.end {
+            fmt.Fprintf(&block, "      | ... (%d more lines)\n", b.end-end)
+        }
+        t := estimateTokens(block.String())
+        if tokens+t > budget {
+            break
+        }
+        tokens += t
+        sb.WriteString(block.String())
+    }
+    if tokens == 0 {
+        return ""
+    }
+    return sb.String()
+}
+
+// touchedLines 解析 unified diff，返回新文件中被修改的行号；删除行记为删除位置所在的行
+func touchedLines(diff string) []int {
+    var touched []int
+    newLine := 0
+    inHunk := false
+    for _, l := range strings.Split(diff, "\n") {
+        if m := hunkHeaderPattern.FindStringSubmatch(l); m != nil {
+            newLine = hunkStart(m[3], m[4]) + 1
+            inHunk = true
+            continue
+        }
+        if !inHunk || l == "" {
+            continue
+        }
+        switch l[0] {
+        case '+':
+            touched = append(touched, newLine)
+            newLine++
+        case '-':
+            touched = append(touched, max(newLine, 1))
+        case ' ':
+            newLine++
+        }
+    }
+    return touched
+}
+
+// enclosingBlocks 找到包含各个修改行的定义，按起始行排序并去重
+func enclosingBlocks(path, content string, lines []string, touched []int) []lineRange {
+    var find func(line int) (lineRange, bool)
+    if strings.EqualFold(filepath.Ext(path), ".go") {
+        if decls := goDecls(path, content); decls != nil {
+            find = func(line int) (lineRange, bool) {
+                for _, d := range decls {
+                    if line >= d.start && line <= d.end {
+                        return d, true
+                    }
+                }
+                return lineRange{}, false
+            }
+        }
+    }
+    if find == nil {
+        find = func(line int) (lineRange, bool) {
+            return heuristicBlock(lines, line)
+        }
+    }
+
+    seen := map[lineRange]bool{}
+    var blocks []lineRange
+    for _, line := range touched {
+        if line < 1 || line > len(lines) {
+            continue
+        }
+        if b, ok := find(line); ok && !seen[b] {
+            seen[b] = true
+            blocks = append(blocks, b)
+        }
+    }
+    sort.Slice(blocks, func(i, j int) bool { return blocks[i].start < blocks[j].start })
+    return blocks
+}
+
+// goDecls 使用 go/ast 取出顶层声明（函数、方法、type / var / const 块）的行号区间，含文档注释
+func goDecls(path, content string) []lineRange {
+    fset := token.NewFileSet()
+    f, _ := parser.ParseFile(fset, path, content, parser.ParseComments)
+    if f == nil {
+        return nil
+    }
+    decls := []lineRange{}
+    for _, decl := range f.Decls {
+        start := decl.Pos()
+        switch d := decl.(type) {
+        case *ast.FuncDecl:
+            if d.Doc != nil {
+                start = d.Doc.Pos()
+            }
+        case *ast.GenDecl:
+            if d.Tok == token.IMPORT {
+                continue
+            }
+            if d.Doc != nil {
+                start = d.Doc.Pos()
+            }
+        default:
+            continue
+        }
+        decls = append(decls, lineRange{fset.Position(start).Line, fset.Position(decl.End()).Line})
+    }
+    return decls
+}
+
+// heuristicBlock 非 Go 文件：向上找到缩进不大于修改行的最近定义，再按花括号配对或缩进确定结束行
+func heuristicBlock(lines []string, line int) (lineRange, bool) {
+    limit := indentOf(lines[line-1])
+    for i := line - 1; i >= 0; i-- {
+        l := lines[i]
+        if strings.TrimSpace(l) == "" {
+            continue
+        }
+        indent := indentOf(l)
+        if indent > limit {
+            continue
+        }
+        if isDefinition(l) {
+            if end := blockEnd(lines, i); end >= line {
+                return lineRange{i + 1, end}, true
+            }
+            // 已结束的同级定义，继续查找外层
+            limit = indent - 1
+            continue
+        }
+        // 缩进更小的非定义行（如 if、for）继续向外层查找
+        limit = indent
+    }
+    return lineRange{}, false
+}
+
+func isDefinition(l string) bool {
+    if controlKeyword.MatchString(l) {
+        return false
+    }
+    for _, re := range definitionPatterns {
+        if re.MatchString(l) {
+            return true
+        }
+    }
+    return false
+}
+
+// blockEnd 定义的结束行：定义开始几行内出现 { 时按花括号配对，否则按缩进（Python、Ruby 等）
+func blockEnd(lines []string, header int) int {
+    braceLang := false
+    for i := header; i < min(header+3, len(lines)); i++ {
+        if strings.Contains(lines[i], "{") {
+            braceLang = true
+            break
+        }
+        if strings.HasSuffix(strings.TrimSpace(lines[i]), ":") {
+            break
+        }
+    }
+
+    if braceLang {
+        depth, opened := 0, false
+        for i := header; i < len(lines); i++ {
+            for _, c := range stripStrings(lines[i]) {
+                switch c {
+                case '{':
+                    depth++
+                    opened = true
+                case '}':
+                    depth--
+                }
+            }
+            if opened && depth <= 0 {
+                return i + 1
+            }
+        }
+        return len(lines)
+    }
+
+    base := indentOf(lines[header])
+    end := header
+    for i := header + 1; i < len(lines); i++ {
+        if strings.TrimSpace(lines[i]) == "" {
+            continue
+        }
+        if indentOf(lines[i]) <= base {
+            break
+        }
+        end = i
+    }
+    return end + 1
+}
+
+var stringLiteral = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`[^`]*`")
+
+// stripStrings 去掉单行中的字符串字面量，避免其中的花括号影响配对
+func stripStrings(l string) string {
+    if i := strings.Index(l, "//"); i >= 0 && !strings.Contains(l[:i], `"`) {
+        l = l[:i]
+    }
+    return stringLiteral.ReplaceAllString(l, `""`)
+}
+
+// indentOf 行首空白宽度，制表符按 4 计
+func indentOf(l string) int {
+    n := 0
+    for _, c := range l {
+        switch c {
+        case ' ':
+            n++
+        case '\t':
+            n += 4
+        default:
+            return n
+        }
+    }
+    return n
+}
+
+// parseContextMode 校验 --context 取值，空值使用 function
+func parseContextMode(mode string) (string, error) {
+    switch mode {
+    case "":
+        return ContextFunction, nil
+    case ContextFull, ContextFunction, ContextNone:
+        return mode, nil
+    }
+    return "", fmt.Errorf("unsupported context: %s (expect %s, %s or %s)", mode, ContextFull, ContextFunction, ContextNone)
+}
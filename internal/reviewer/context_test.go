@@ -0,0 +1,72 @@
+package reviewer
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+// longGoFile 生成一个超过 maxContextBlockLines 行的 Go 文件，全部语句位于同一个函数中
+func longGoFile(statements int, last string) string {
+    var sb strings.Builder
+    sb.WriteString("package a\n\nfunc Long() {\n")
+    for i := 0; i < statements; i++ {
+        fmt.Fprintf(&sb, "\tx%d := %d\n\t_ = x%d\n", i, i, i)
+    }
+    sb.WriteString("\t" + last + "\n}\n")
+    return sb.String()
+}
+
+func TestFileContextFullKeepsWholeFile(t *testing.T) {
+    oldContent := longGoFile(100, "println(\"old\")")
+    newContent := longGoFile(100, "println(\"new\")")
+    lines := strings.Count(newContent, "\n")
+    if lines <= maxContextBlockLines {
+        t.Fatalf("test file has %d lines, want more than %d", lines, maxContextBlockLines)
+    }
+    d := gitDiff{
+        FilePath:   "a.go",
+        Content:    unifiedDiff("a.go", "a.go", oldContent, newContent, 3),
+        ChangeType: changeModified,
+        OldContent: oldContent,
+        NewContent: newContent,
+    }
+
+    e := NewEngine(context.Background(), EngineConfig{Context: ContextFull, ChunkTokens: 100000})
+    got := e.fileContext(d)
+    if !strings.Contains(got, fmt.Sprintf("--- lines 1-%d ---", lines)) {
+        t.Errorf("full context does not cover the whole file:\n%s", got)
+    }
+    if !strings.Contains(got, fmt.Sprintf("%5d | \tprintln(\"new\")", lines-1)) {
+        t.Errorf("full context lacks the changed line %d", lines-1)
+    }
+    if strings.Contains(got, "more lines") {
+        t.Errorf("full context was truncated")
+    }
+
+    // function 模式仍按单个定义的行数上限截断
+    e = NewEngine(context.Background(), EngineConfig{Context: ContextFunction, ChunkTokens: 100000})
+    if got := e.fileContext(d); !strings.Contains(got, "more lines") {
+        t.Errorf("function context of a long function was not truncated:\n%s", got)
+    }
+}
+
+func TestFileContextFullOverBudget(t *testing.T) {
+    oldContent := longGoFile(50, "") + "\nfunc B() {\n\tprintln(2)\n}\n"
+    newContent := strings.Replace(oldContent, "println(2)", "println(3)", 1)
+    start := strings.Count(oldContent, "\n") - 2
+    d := gitDiff{
+        FilePath:   "a.go",
+        Content:    unifiedDiff("a.go", "a.go", oldContent, newContent, 0),
+        ChangeType: changeModified,
+        OldContent: oldContent,
+        NewContent: newContent,
+    }
+    // 完整文件超出预算时退回 function
+    e := NewEngine(context.Background(), EngineConfig{Context: ContextFull, ChunkTokens: estimateTokens(newContent) - 1})
+    got := e.fileContext(d)
+    if !strings.Contains(got, fmt.Sprintf("--- lines %d-%d ---", start, start+2)) || strings.Contains(got, "func Long") {
+        t.Errorf("fileContext() over budget =\n%s\nwant only func B", got)
+    }
+}
@@ -0,0 +1,266 @@
+package reviewer
+
+import (
+    "bytes"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/fatih/color"
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/format/gitignore"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+    // 删除文件中最多检索的符号数
+    maxDeletedSymbols = 30
+    // 引用位置总数与单个符号的上限
+    maxReferences          = 30
+    maxReferencesPerSymbol = 5
+    // 随删除审查附带的原文件行数
+    maxDeletedPreviewLines = 80
+    // 检索引用时跳过的大文件
+    maxReferenceFileSize = 1 << 20
+)
+
+// 以 function、class、def、fn 等关键字开头的定义，也用于识别上下文所在的定义
+var keywordDefinitionPattern = regexp.MustCompile(`(?m)^\s*(?:export\s+)?(?:default\s+)?(?:public\s+|private\s+|protected\s+)?(?:static\s+)?(?:async\s+)?(?:function|class|interface|enum|def|fn|struct|trait)\s+([A-Za-z_]\w*)`)
+
+// 常见语言的顶层定义：Go 的 func/type，以及关键字开头的定义
+var symbolPatterns = []*regexp.Regexp{
+    regexp.MustCompile(`(?m)^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+    regexp.MustCompile(`(?m)^type\s+([A-Za-z_]\w*)`),
+    keywordDefinitionPattern,
+}
+
+// 引用片段的最大字节数
+const maxExcerptBytes = 160
+
+// clipLine 去掉首尾空白，过长时在 UTF-8 字符边界处截断，避免 prompt 中出现半个多字节字符
+func clipLine(line string) string {
+    text := strings.TrimSpace(line)
+    if len(text) <= maxExcerptBytes {
+        return text
+    }
+    cut := maxExcerptBytes
+    for cut > 0 && !utf8.RuneStart(text[cut]) {
+        cut--
+    }
+    return text[:cut] + "..."
+}
+
+// fileWalker 遍历仓库中的文件，用于检索被删除符号的引用
+type fileWalker func(visit func(path, content string)) error
+
+// reference 仓库中仍引用被删除符号的位置
+type reference struct {
+    symbol string
+    file   string
+    line   int
+    text   string
+}
+
+// deletedSymbols 提取被删除文件中定义的符号
+func deletedSymbols(content string) []string {
+    seen := map[string]bool{}
+    var symbols []string
+    for _, re := range symbolPatterns {
+        for _, m := range re.FindAllStringSubmatch(content, -1) {
+            name := m[1]
+            if len(name) < 3 || name == "main" || name == "init" || strings.HasPrefix(name, "Test") || seen[name] {
+                continue
+            }
+            seen[name] = true
+            symbols = append(symbols, name)
+            if len(symbols) >= maxDeletedSymbols {
+                return symbols
+            }
+        }
+    }
+    return symbols
+}
+
+// findReferences 遍历一次仓库文件，按单词边界检索所有符号，每个符号最多记录 maxReferencesPerSymbol 处
+func findReferences(symbols []string, walk fileWalker) ([]reference, error) {
+    if len(symbols) == 0 {
+        return nil, nil
+    }
+    quoted := make([]string, len(symbols))
+    for i, s := range symbols {
+        quoted[i] = regexp.QuoteMeta(s)
+    }
+    re := regexp.MustCompile(`\b(` + strings.Join(quoted, "|") + `)\b`)
+
+    var refs []reference
+    perSymbol := map[string]int{}
+    err := walk(func(path, content string) {
+        if !re.MatchString(content) {
+            return
+        }
+        for i, line := range strings.Split(content, "\n") {
+            m := re.FindStringSubmatch(line)
+            if m == nil || perSymbol[m[1]] >= maxReferencesPerSymbol {
+                continue
+            }
+            perSymbol[m[1]]++
+            refs = append(refs, reference{symbol: m[1], file: path, line: i + 1, text: clipLine(line)})
+        }
+    })
+    return refs, err
+}
+
+// referencesTo 筛选引用了 symbols 中符号的位置，最多 maxReferences 处
+func referencesTo(refs []reference, symbols []string) []reference {
+    var out []reference
+    for _, r := range refs {
+        if slices.Contains(symbols, r.symbol) {
+            out = append(out, r)
+            if len(out) >= maxReferences {
+                break
+            }
+        }
+    }
+    return out
+}
+
+// deletedDiffs 生成删除文件的轻量审查内容，附带仓库中仍引用其符号的位置
+//
+// 所有删除文件的符号合并后只遍历一次仓库，移动整个目录时不会按删除文件数重复读取仓库
+func (e *Engine) deletedDiffs(deleted map[string]string, walk fileWalker) []gitDiff {
+    var diffs []gitDiff
+    fileSymbols := map[string][]string{}
+    var all []string
+    for file, oldContent := range deleted {
+        if reason := e.unreviewable(file, 0, oldContent); reason != "" {
+            diffs = append(diffs, skippedDiff(file, "", changeDeleted, reason))
+            continue
+        }
+        if e.skipGenerated(file, oldContent) {
+            continue
+        }
+        symbols := deletedSymbols(oldContent)
+        fileSymbols[file] = symbols
+        for _, sym := range symbols {
+            if !slices.Contains(all, sym) {
+                all = append(all, sym)
+            }
+        }
+    }
+    if len(fileSymbols) == 0 {
+        return diffs
+    }
+
+    refs, err := findReferences(all, walk)
+    if err != nil {
+        color.Red("failed to search references: err=%v\n", err)
+        return diffs
+    }
+    for file, symbols := range fileSymbols {
+        content := deletedFileContent(file, deleted[file], symbols, referencesTo(refs, symbols))
+        color.Yellow("Δ del: %s\n", file)
+        diffs = append(diffs, gitDiff{FilePath: file, Content: content, ChangeType: changeDeleted})
+    }
+    return diffs
+}
+
+// deletedFileContent 生成删除文件的审查内容：定义的符号、仓库中仍存在的引用、原文件开头部分
+func deletedFileContent(path, oldContent string, symbols []string, refs []reference) string {
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "deleted file: %s\n\n", path)
+    if len(symbols) == 0 {
+        sb.WriteString("symbols defined in the deleted file: (none detected)\n\n")
+    } else {
+        fmt.Fprintf(&sb, "symbols defined in the deleted file: %s\n\n", strings.Join(symbols, ", "))
+    }
+    if len(refs) == 0 {
+        sb.WriteString("references to these symbols still present in the repository: (none found)\n\n")
+    } else {
+        sb.WriteString("references to these symbols still present in the repository:\n")
+        for _, r := range refs {
+            fmt.Fprintf(&sb, "%s:%d: %s\n", r.file, r.line, r.text)
+        }
+        sb.WriteString("\n")
+    }
+
+    lines := strings.Split(strings.TrimRight(oldContent, "\n"), "\n")
+    fmt.Fprintf(&sb, "removed content (%d lines", len(lines))
+    if len(lines) > maxDeletedPreviewLines {
+        fmt.Fprintf(&sb, ", first %d shown", maxDeletedPreviewLines)
+        lines = lines[:maxDeletedPreviewLines]
+    }
+    sb.WriteString("):\n")
+    for _, l := range lines {
+        sb.WriteString("-" + l + "\n")
+    }
+    return sb.String()
+}
+
+// worktreeWalker 遍历工作区文件，跳过 .git、.gitignore 与忽略规则命中的文件以及二进制、大文件
+func (e *Engine) worktreeWalker(worktree *git.Worktree) fileWalker {
+    return func(visit func(path, content string)) error {
+        patterns, _ := gitignore.ReadPatterns(worktree.Filesystem, nil)
+        gitIgnored := gitignore.NewMatcher(patterns)
+        return filepath.WalkDir(e.repoRoot, func(p string, d fs.DirEntry, err error) error {
+            if err != nil {
+                return nil
+            }
+            rel, err := filepath.Rel(e.repoRoot, p)
+            if err != nil || rel == "." {
+                return nil
+            }
+            parts := strings.Split(filepath.ToSlash(rel), "/")
+            if d.IsDir() {
+                if d.Name() == ".git" || gitIgnored.Match(parts, true) || (e.ignore != nil && e.ignore.matcher.Match(parts, true)) {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+            if !d.Type().IsRegular() || gitIgnored.Match(parts, false) || (e.ignore != nil && e.ignore.ignored(filepath.ToSlash(rel))) {
+                return nil
+            }
+            if info, err := d.Info(); err != nil || info.Size() > maxReferenceFileSize {
+                return nil
+            }
+            content, err := os.ReadFile(p)
+            if err != nil || isBinary(content) {
+                return nil
+            }
+            visit(filepath.ToSlash(rel), string(content))
+            return nil
+        })
+    }
+}
+
+// treeWalker 遍历某个 commit 的 tree，用于提交 / 区间审查
+func (e *Engine) treeWalker(tree *object.Tree) fileWalker {
+    return func(visit func(path, content string)) error {
+        if tree == nil {
+            return nil
+        }
+        return tree.Files().ForEach(func(f *object.File) error {
+            if f.Size > maxReferenceFileSize || (e.ignore != nil && e.ignore.ignored(f.Name)) {
+                return nil
+            }
+            if bin, err := f.IsBinary(); err != nil || bin {
+                return nil
+            }
+            content, err := f.Contents()
+            if err != nil {
+                return nil
+            }
+            visit(f.Name, content)
+            return nil
+        })
+    }
+}
+
+// isBinary 与 git 相同，前 8000 字节中出现 NUL 即视为二进制
+func isBinary(content []byte) bool {
+    return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
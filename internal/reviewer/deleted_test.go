@@ -0,0 +1,32 @@
+package reviewer
+
+import (
+    "strings"
+    "testing"
+    "unicode/utf8"
+)
+
+func TestClipLine(t *testing.T) {
+    tests := []struct {
+        name string
+        line string
+        want string
+    }{
+        {"short", "  foo()  ", "foo()"},
+        {"exact", strings.Repeat("a", maxExcerptBytes), strings.Repeat("a", maxExcerptBytes)},
+        {"ascii", strings.Repeat("a", maxExcerptBytes+1), strings.Repeat("a", maxExcerptBytes) + "..."},
+        // 第 160 个字节落在三字节汉字的中间
+        {"multibyte", "a" + strings.Repeat("中", 60), "a" + strings.Repeat("中", 53) + "..."},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := clipLine(tt.line)
+            if got != tt.want {
+                t.Errorf("clipLine() = %q, want %q", got, tt.want)
+            }
+            if !utf8.ValidString(got) {
+                t.Errorf("clipLine() returned invalid UTF-8: %q", got)
+            }
+        })
+    }
+}
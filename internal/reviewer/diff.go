@@ -5,30 +5,63 @@ import (
     "io"
     "os"
     "path/filepath"
-    "strings"
 
     "github.com/fatih/color"
     "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
     "github.com/go-git/go-git/v5/plumbing/object"
-    "github.com/sergi/go-diff/diffmatchpatch"
 )
 
 type gitDiff struct {
     // 文件
     FilePath string
+    // 重命名前的路径，仅 renamed 时有值
+    OldPath string
     // 变更内容
     Content string
+    // 变更类型：added / modified / renamed / deleted
+    ChangeType string
+    // 二进制或超过大小上限的文件不发送给模型，报告中记录跳过原因
+    SkipReason string
+    // 超出 token 预算被拆分时，当前为第 Chunk 部分，共 Chunks 部分
+    Chunk  int
+    Chunks int
+    // 合并审查的批次中包含的文件，仅 ChangeType 为 batch 时有值
+    Files []gitDiff
+    // 变更后的完整内容，用于附带 hunk 所在函数等上下文，仅 modified / renamed 时有值
+    NewContent string
+    // 变更前的完整内容，用于找出签名变化的 Go 符号，仅 modified / renamed 时有值
+    OldContent string
 }
 
+const (
+    changeAdded    = "added"
+    changeModified = "modified"
+    changeRenamed  = "renamed"
+    changeDeleted  = "deleted"
+)
+
 func (e *Engine) gitDiff() ([]gitDiff, error) {
-    workPath, err := e.getWorkPath()
+    repo, err := e.openRepo()
     if err != nil {
         return nil, err
     }
+    workPath := e.repoRoot
 
-    repo, err := git.PlainOpen(workPath)
-    if err != nil {
-        return nil, fmt.Errorf("failed to open repo: workPath=%s, err=%v", workPath, err)
+    // 指定 commit 时审查该提交本身的变更，而非工作区
+    if e.cfg.CommitID != "" {
+        return e.commitDiff(repo, e.cfg.CommitID)
+    }
+    // 指定区间时审查整个区间的变更
+    if e.cfg.BaseRef != "" {
+        return e.rangeDiff(repo, e.cfg.BaseRef, e.cfg.HeadRef)
+    }
+    if e.cfg.HeadRef != "" {
+        return nil, fmt.Errorf("--head requires --base")
+    }
+    // 只审查暂存区，即 git commit 将要提交的内容
+    if e.cfg.Staged {
+        return e.stagedDiff(repo)
     }
 
     // 获取HEAD commit
@@ -41,6 +74,7 @@ func (e *Engine) gitDiff() ([]gitDiff, error) {
     if err != nil {
         return nil, fmt.Errorf("failed to get commit: %v", err)
     }
+    e.target = fmt.Sprintf("worktree vs %s (%s)", ref.Name().Short(), ref.Hash().String()[:7])
 
     worktree, err := repo.Worktree()
     if err != nil {
@@ -59,61 +93,178 @@ func (e *Engine) gitDiff() ([]gitDiff, error) {
     }
 
     diffs := []gitDiff{}
+    // 新增与删除的文件先收集起来，配对识别重命名后再生成变更
+    added := map[string]string{}
+    deleted := map[string]string{}
     for file, fileStatus := range status {
-        // 可选过滤：常见无关文件
-        if file == "go.sum" || file == "go.mod" || strings.Contains(strings.ToLower(file), "readme") {
+        if e.skipFile(file) {
             continue
         }
-        // 1. 未追踪文件：直接读取内容
-        if fileStatus.Staging == git.Untracked || fileStatus.Worktree == git.Untracked {
-            content, err := e.getFileContent(filepath.Join(workPath, file))
+        switch {
+        // 1. 未追踪文件与已添加到暂存区的新文件：直接读取内容
+        case fileStatus.Staging == git.Untracked || fileStatus.Worktree == git.Untracked || fileStatus.Staging == git.Added:
+            if fileStatus.Worktree == git.Deleted {
+                // 暂存后又从工作区删除，相对 HEAD 没有变化
+                continue
+            }
+            content, reason, err := e.readWorktreeFile(file)
             if err != nil {
                 color.Red("failed to get change path: path=%s, err=%v\n", file, err)
                 continue
             }
-            diffs = append(diffs, gitDiff{FilePath: file, Content: content})
-            color.Yellow("Δ add: %s\n", filepath.Join(workPath, file))
-        }
-        // 2. 已修改文件：生成 diff
-        if fileStatus.Staging == git.Modified || fileStatus.Worktree == git.Modified {
-            diffContent, err := e.getModifiedFileDiff(repo, headTree, file, workPath)
+            if reason != "" {
+                diffs = append(diffs, skippedDiff(file, "", changeAdded, reason))
+                continue
+            }
+            added[file] = content
+        // 2. 已删除文件：读取 HEAD 中的内容
+        case fileStatus.Staging == git.Deleted || fileStatus.Worktree == git.Deleted:
+            oldContent, err := e.getHeadFileContent(repo, headTree, file)
             if err != nil {
-                color.Red("failed to get diff for file: path=%s, err=%v\n", file, err)
+                color.Red("failed to get deleted file content: path=%s, err=%v\n", file, err)
                 continue
             }
-            diffs = append(diffs, gitDiff{FilePath: file, Content: diffContent})
-            color.Yellow("Δ mod: %s\n", filepath.Join(workPath, file))
-        }
-        // 3. 已添加到暂存区的新文件
-        if fileStatus.Staging == git.Added {
-            content, err := e.getFileContent(filepath.Join(workPath, file))
+            // 删除文件只附带开头部分，不受大小上限限制
+            if reason := e.unreviewable(file, 0, oldContent); reason != "" {
+                diffs = append(diffs, skippedDiff(file, "", changeDeleted, reason))
+                continue
+            }
+            deleted[file] = oldContent
+        // 3. 已修改文件：生成 diff
+        case fileStatus.Staging == git.Modified || fileStatus.Worktree == git.Modified:
+            oldContent, newContent, err := e.getModifiedFileContents(repo, headTree, file, workPath)
             if err != nil {
-                color.Red("failed to get file content: path=%s, err=%v\n", file, err)
+                color.Red("failed to get diff for file: path=%s, err=%v\n", file, err)
+                continue
+            }
+            if reason := e.unreviewable(file, len(newContent), oldContent, newContent); reason != "" {
+                diffs = append(diffs, skippedDiff(file, "", changeModified, reason))
+                continue
+            }
+            if e.skipGenerated(file, newContent) {
                 continue
             }
-            diffs = append(diffs, gitDiff{FilePath: file, Content: content})
-            color.Yellow("Δ staged: %s\n", filepath.Join(workPath, file))
+            diffContent := e.generateProfessionalDiff(file, oldContent, newContent)
+            diffs = append(diffs, gitDiff{FilePath: file, Content: diffContent, ChangeType: changeModified, NewContent: newContent, OldContent: oldContent})
+            color.Yellow("Δ mod: %s\n", filepath.Join(workPath, file))
         }
     }
-    return diffs, nil
+
+    e.walker = e.worktreeWalker(worktree)
+    return e.pairChanges(diffs, added, deleted), nil
 }
 
-func (e *Engine) getWorkPath() (string, error) {
-    var workPath string
-    if e.cfg.ReviewPath == "" || e.cfg.ReviewPath == "." {
+// pairChanges 新增与删除的文件按内容相似度配对为重命名（go-git 的 status 与 index 都不识别重命名），
+// 其余分别生成新增与删除的变更；删除的变更通过 e.walker 查找残留引用
+func (e *Engine) pairChanges(diffs []gitDiff, added, deleted map[string]string) []gitDiff {
+    for _, pair := range detectRenames(deleted, added, e.cfg.RenameThreshold) {
+        if d, ok := e.renameDiff(pair.oldPath, pair.newPath, deleted[pair.oldPath], added[pair.newPath], pair.score); ok {
+            diffs = append(diffs, d)
+        }
+        delete(deleted, pair.oldPath)
+        delete(added, pair.newPath)
+    }
+    for file, content := range added {
+        if e.skipGenerated(file, content) {
+            continue
+        }
+        diffs = append(diffs, gitDiff{FilePath: file, Content: content, ChangeType: changeAdded})
+        color.Yellow("Δ add: %s\n", filepath.Join(e.repoRoot, file))
+    }
+    return append(diffs, e.deletedDiffs(deleted, e.walker)...)
+}
+
+// skipFile 过滤不在审查路径范围内的文件，以及命中忽略规则的文件
+func (e *Engine) skipFile(file string) bool {
+    if e.ignore != nil && e.ignore.ignored(file) {
+        return true
+    }
+    if len(e.pathspecs) == 0 {
+        return false
+    }
+    for _, spec := range e.pathspecs {
+        if spec.match(file) {
+            return false
+        }
+    }
+    return true
+}
+
+// openRepo 从审查路径向上查找所在的 Git 仓库，记录仓库根目录并将审查路径转换为 pathspec
+func (e *Engine) openRepo() (*git.Repository, error) {
+    start, err := e.discoveryPath()
+    if err != nil {
+        return nil, err
+    }
+    repo, err := git.PlainOpenWithOptions(start, &git.PlainOpenOptions{DetectDotGit: true})
+    if err != nil {
+        return nil, fmt.Errorf("failed to open repo: path=%s, err=%v", start, err)
+    }
+    worktree, err := repo.Worktree()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get work tree: %v", err)
+    }
+    e.repoRoot = worktree.Filesystem.Root()
+
+    e.pathspecs = nil
+    for _, p := range e.cfg.ReviewPaths {
+        spec, err := newPathspec(e.repoRoot, p)
+        if err != nil {
+            return nil, err
+        }
+        e.pathspecs = append(e.pathspecs, spec)
+    }
+    e.ignore, err = e.loadIgnoreRules()
+    if err != nil {
+        return nil, err
+    }
+    return repo, nil
+}
+
+// discoveryPath 查找仓库的起点：第一个审查路径所在目录（路径不存在时取最近的已存在父目录），未指定或为 glob 时为当前目录
+func (e *Engine) discoveryPath() (string, error) {
+    if len(e.cfg.ReviewPaths) == 0 || hasGlobMeta(e.cfg.ReviewPaths[0]) {
         wd, err := os.Getwd()
         if err != nil {
             return "", fmt.Errorf("get work path failed: %v", err)
         }
-        workPath = wd
-    } else {
-        abs, err := filepath.Abs(e.cfg.ReviewPath)
-        if err != nil {
-            return "", fmt.Errorf("convert to abs path failed: %v", err)
+        return wd, nil
+    }
+    abs, err := filepath.Abs(e.cfg.ReviewPaths[0])
+    if err != nil {
+        return "", fmt.Errorf("convert to abs path failed: %v", err)
+    }
+    for {
+        info, err := os.Stat(abs)
+        if err == nil {
+            if info.IsDir() {
+                return abs, nil
+            }
+            return filepath.Dir(abs), nil
+        }
+        parent := filepath.Dir(abs)
+        if parent == abs {
+            return "", fmt.Errorf("path not found: %s", e.cfg.ReviewPaths[0])
         }
-        workPath = abs
+        abs = parent
+    }
+}
+
+// skipGenerated 跳过生成代码，content 为变更后的文件内容
+func (e *Engine) skipGenerated(file, content string) bool {
+    if e.ignore == nil || !e.ignore.generated(file, content) {
+        return false
     }
-    return workPath, nil
+    color.Yellow("⊘ skip generated: %s\n", file)
+    return true
+}
+
+// getWorkPath 返回仓库根目录，报告文件写在此处
+func (e *Engine) getWorkPath() (string, error) {
+    if e.repoRoot == "" {
+        return "", fmt.Errorf("repository is not opened")
+    }
+    return e.repoRoot, nil
 }
 
 func (e *Engine) getFileContent(filePath string) (string, error) {
@@ -124,35 +275,66 @@ func (e *Engine) getFileContent(filePath string) (string, error) {
     return string(content), nil
 }
 
-func (e *Engine) getModifiedFileDiff(repo *git.Repository, headTree *object.Tree, filePath, workPath string) (string, error) {
-    // 获取HEAD中的文件内容
-    var oldContent string
-    if entry, err := headTree.FindEntry(filePath); err == nil {
-        blob, err := repo.BlobObject(entry.Hash)
-        if err != nil {
-            return "", fmt.Errorf("failed to get blob: %v", err)
-        }
-        reader, err := blob.Reader()
-        if err != nil {
-            return "", fmt.Errorf("failed to get blob reader: %v", err)
-        }
-        defer reader.Close()
-        content, err := io.ReadAll(reader)
-        if err != nil {
-            return "", fmt.Errorf("failed to read blob content: %v", err)
-        }
-        oldContent = string(content)
+// getModifiedFileContents 返回文件在 HEAD 与工作区中的内容
+func (e *Engine) getModifiedFileContents(repo *git.Repository, headTree *object.Tree, filePath, workPath string) (string, string, error) {
+    oldContent, err := e.getHeadFileContent(repo, headTree, filePath)
+    if err != nil {
+        return "", "", err
     }
     // 获取当前工作区的文件内容
     newContent, err := e.getFileContent(filepath.Join(workPath, filePath))
     if err != nil {
-        return "", fmt.Errorf("failed to get current file content: %v", err)
+        return "", "", fmt.Errorf("failed to get current file content: %v", err)
+    }
+    return oldContent, newContent, nil
+}
+
+// getHeadFileContent 获取HEAD中的文件内容，文件不存在时返回空串
+func (e *Engine) getHeadFileContent(repo *git.Repository, headTree *object.Tree, filePath string) (string, error) {
+    entry, err := headTree.FindEntry(filePath)
+    if err != nil {
+        return "", nil
     }
-    return e.generateProfessionalDiff(filePath, oldContent, newContent), nil
+    return blobContent(repo, entry.Hash)
 }
 
+// blobContent 读取 blob 对象的内容
+func blobContent(repo *git.Repository, hash plumbing.Hash) (string, error) {
+    blob, err := repo.BlobObject(hash)
+    if err != nil {
+        return "", fmt.Errorf("failed to get blob: %v", err)
+    }
+    reader, err := blob.Reader()
+    if err != nil {
+        return "", fmt.Errorf("failed to get blob reader: %v", err)
+    }
+    defer reader.Close()
+    content, err := io.ReadAll(reader)
+    if err != nil {
+        return "", fmt.Errorf("failed to read blob content: %v", err)
+    }
+    return string(content), nil
+}
+
+// generateProfessionalDiff 生成 unified diff，便于模型区分增删并引用新文件行号
 func (e *Engine) generateProfessionalDiff(filePath, oldContent, newContent string) string {
-    dmp := diffmatchpatch.New()
-    diffs := dmp.DiffMain(oldContent, newContent, false)
-    return dmp.DiffPrettyText(diffs)
+    return unifiedDiff(filePath, filePath, oldContent, newContent, e.cfg.ContextLines)
+}
+
+// renameDiff 生成重命名文件相对原路径的 diff；内容未变化的纯重命名无需审查
+func (e *Engine) renameDiff(oldPath, newPath, oldContent, newContent string, score int) (gitDiff, bool) {
+    if oldContent == newContent {
+        color.Yellow("→ rename: %s -> %s (unchanged)\n", oldPath, newPath)
+        return gitDiff{}, false
+    }
+    if reason := e.unreviewable(newPath, len(newContent), oldContent, newContent); reason != "" {
+        return skippedDiff(newPath, oldPath, changeRenamed, reason), true
+    }
+    if e.skipGenerated(newPath, newContent) {
+        return gitDiff{}, false
+    }
+    header := fmt.Sprintf("similarity index %d%%\nrename from %s\nrename to %s\n", score, oldPath, newPath)
+    content := header + unifiedDiff(oldPath, newPath, oldContent, newContent, e.cfg.ContextLines)
+    color.Yellow("Δ ren: %s -> %s (%d%%)\n", oldPath, newPath, score)
+    return gitDiff{FilePath: newPath, OldPath: oldPath, Content: content, ChangeType: changeRenamed, NewContent: newContent, OldContent: oldContent}, true
 }
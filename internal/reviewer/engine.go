@@ -5,20 +5,44 @@ import (
     "fmt"
     config "stellarspec/internal/model/conf"
     "sync"
+    "text/template"
+    "time"
 
-    "github.com/cloudwego/eino-ext/components/model/openai"
     "github.com/fatih/color"
 )
 
 // EngineConfig 承载从 CLI 映射的参数（部分暂不启用）
 type EngineConfig struct {
-    ReviewPath    string
+    ReviewPaths   []string // 审查范围：文件、目录或 glob，为空时审查整个仓库
     MaxWorkers    int
     CommitID      string
+    BaseRef       string // 区间起点，也可为 A..B / A...B 表达式
+    HeadRef       string // 区间终点，默认 HEAD
+    Staged        bool   // 只审查暂存区相对 HEAD 的变更
     PromptPath    string
     ThinkingChain bool
-    OutputFile    string
+    OutputFile    string // 报告路径，相对路径以仓库根目录为基准
+    OutputMode    string // 报告写入方式：overwrite（默认）/ append / timestamped
+    SortBy        string // 报告中文件的顺序：path（默认）/ severity
+    FailOn        string // 存在达到该等级的问题时 Run 返回 FindingsError，为空时不检查
+    Format        string // 报告格式：markdown（默认）/ sarif
+    ContextLines  int    // unified diff 的上下文行数
+    AdaptivePool  bool   // 根据模型端限流/5xx 自动收缩与恢复并发
     Language      string
+
+    Includes        []string // 强制审查的 gitignore 风格规则，优先级最高
+    Excludes        []string // 额外忽略的 gitignore 风格规则
+    NoDefaultIgnore bool     // 关闭默认忽略规则（锁文件、vendor、生成代码等）
+    RenameThreshold int      // 重命名识别的相似度阈值（0-100），0 表示不识别
+    MaxFileSize     int      // 发送给模型的单个文件大小上限（KB），0 表示不限制
+    ChunkTokens     int      // 单次审查中变更内容的 token 预算，超出时按 hunk 拆分，0 表示不拆分
+    Batch           bool     // 将多个小文件合并到一次审查请求中
+    SummaryPass     bool     // 逐文件审查完成后生成变更集总览，置于报告开头
+    Context         string   // diff 之外附带的上下文：full / function（默认）/ none
+
+    Retries        int           // 超时、限流、5xx 与网络错误的重试次数
+    RequestTimeout time.Duration // 单次模型请求的超时，0 表示不限制
+    CacheDir       string        // 审查结果缓存目录，为空时不使用缓存
 }
 
 // Engine 负责编排：拉取变更 -> 并发审查 -> 写报告
@@ -26,54 +50,219 @@ type Engine struct {
     ctx context.Context
 
     cfg       EngineConfig
-    chatModel *openai.ChatModel // 模型客户端
+    promptTpl *template.Template // --prompt-file 加载的模板，nil 时使用内置 prompt
+
+    // 模型回退链：主模型在前，之后为配置中的回退模型
+    models   []*chatModelEntry
+    modelsMu sync.Mutex
+    cache    *reviewCache
+
+    repoRoot  string     // 仓库根目录
+    pathspecs []pathspec // 由 ReviewPaths 转换而来
+    ignore    *ignoreRules
+    target    string    // 审查范围的描述（工作区 / commit / 区间），写入报告头
+    started   time.Time // 运行开始时间
 
-    // 文件写入互斥
+    // 审查结果互斥
     mutex sync.Mutex
+    // 全部审查完成后排序并统一写入报告
+    reviews []*fileReview
+    // 终端进度：已完成与全部文件数
+    done, total int
+
+    // 遍历变更后仓库中的文件，用于查找被删除或签名变化的符号的引用
+    walker      fileWalker
+    goIndexOnce sync.Once
+    goIndex     *goIndex
 }
 
 func NewEngine(ctx context.Context, cfg EngineConfig) *Engine {
-    return &Engine{ctx: ctx, cfg: cfg}
+    e := &Engine{ctx: ctx, cfg: cfg}
+    if cfg.CacheDir != "" {
+        e.cache = &reviewCache{dir: cfg.CacheDir}
+    }
+    return e
 }
 
-// CreateModel 根据基础配置创建模型客户端
+// CreateModel 根据基础配置创建主模型与回退模型的客户端
 func (e *Engine) CreateModel(conf *config.BaseConfig) error {
     if conf == nil {
         return fmt.Errorf("nil model config")
     }
-    cm, err := newChatModel(e.ctx, conf)
+    models, err := newModelChain(e.ctx, conf)
     if err != nil {
         return err
     }
-    e.chatModel = cm
+    e.models = models
     return nil
 }
 
 // Run 执行审查流程（返回错误而非 panic）
+//
+// 报告写入后，有文件审查失败时返回 *ReviewFailedError，存在达到 FailOn 等级的问题时返回 *FindingsError
 func (e *Engine) Run() error {
+    switch e.cfg.Format {
+    case "", FormatMarkdown, FormatSARIF:
+    default:
+        return fmt.Errorf("unsupported format: %s (expect %s or %s)", e.cfg.Format, FormatMarkdown, FormatSARIF)
+    }
+    mode, err := parseContextMode(e.cfg.Context)
+    if err != nil {
+        return err
+    }
+    e.cfg.Context = mode
+    if e.cfg.OutputMode, err = parseOutputMode(e.cfg.OutputMode); err != nil {
+        return err
+    }
+    if e.cfg.SortBy, err = parseSortBy(e.cfg.SortBy); err != nil {
+        return err
+    }
+    var failOn Severity
+    if e.cfg.FailOn != "" {
+        if failOn, err = ParseSeverity(e.cfg.FailOn); err != nil {
+            return fmt.Errorf("invalid --fail-on: %w", err)
+        }
+    }
+    e.started = time.Now()
+    if err := e.loadPromptTemplate(); err != nil {
+        return err
+    }
+
     diffs, err := e.gitDiff()
     if err != nil {
         return fmt.Errorf("get git diff failed: %w", err)
     }
 
-    // 为保持行为一致，仍使用默认 10 并发；暂不启用 MaxWorkers
-    maxWorkers := 10
-    semaphore := make(chan struct{}, maxWorkers)
+    pool := newWorkerPool(e.cfg.MaxWorkers, e.cfg.AdaptivePool)
+    e.total = len(diffs)
 
-    var wg sync.WaitGroup
+    var reviewable []gitDiff
     for _, diff := range diffs {
+        if diff.SkipReason != "" {
+            if err := e.recordSkipped(diff); err != nil {
+                color.Red("✖ record skipped failed: %s, err=%v\n", diff.FilePath, err)
+            }
+            continue
+        }
+        reviewable = append(reviewable, diff)
+    }
+    var batches [][]gitDiff
+    if e.cfg.Batch {
+        batches, reviewable = e.planBatches(reviewable)
+    }
+
+    var wg sync.WaitGroup
+    for _, batch := range batches {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if err := e.reviewBatch(pool, batch); err != nil {
+                for _, f := range batch {
+                    e.recordFailed(f, err)
+                }
+            }
+        }()
+    }
+    for _, diff := range reviewable {
         wg.Add(1)
         d := diff
         go func() {
             defer wg.Done()
-            semaphore <- struct{}{}
-            defer func() { <-semaphore }()
-            if err := e.reviewSingleFile(d); err != nil {
-                // 彩色错误输出，但不中断其他任务
-                color.Red("✖ review failed: %s, err=%v\n", d.FilePath, err)
+            if err := e.reviewFile(pool, d); err != nil {
+                // 不中断其他任务，失败原因写入报告
+                e.recordFailed(d, err)
             }
         }()
     }
     wg.Wait()
-    return nil
+    // 结果按完成顺序到达，排序后报告在多次运行之间保持稳定
+    sortReviews(e.reviews, e.cfg.SortBy)
+
+    // 总览失败不影响逐文件结果的输出
+    var summary *changesetSummary
+    if e.cfg.SummaryPass && len(diffs) > 0 {
+        summary, err = e.summarizeChangeset(diffs, e.reviews)
+        if err != nil {
+            color.Red("✖ summary failed: err=%v\n", err)
+        }
+    }
+
+    if e.cfg.Format == FormatSARIF {
+        if err := e.writeSARIF(e.reviews, summary); err != nil {
+            return fmt.Errorf("write sarif failed: %w", err)
+        }
+    } else if err := e.writeMarkdownReport(summary, e.reviews); err != nil {
+        return fmt.Errorf("write report failed: %w", err)
+    }
+    return e.checkResults(e.reviews, failOn)
+}
+
+// 自适应模式下被限流的文件在收缩并发后重新排队的次数上限
+const maxThrottleRequeue = 3
+
+// reviewFile 审查单个文件并写入报告；超出 token 预算的变更拆分后并发审查，再合并为一个报告段落
+func (e *Engine) reviewFile(pool *workerPool, d gitDiff) error {
+    chunks := e.chunkDiff(d)
+    if len(chunks) == 1 {
+        review, err := e.reviewWithPool(pool, d)
+        if err != nil {
+            return err
+        }
+        return e.saveReview(review)
+    }
+
+    color.Yellow("✂ split: %s into %d parts\n", d.FilePath, len(chunks))
+    reviews := make([]*fileReview, len(chunks))
+    errs := make([]error, len(chunks))
+    var wg sync.WaitGroup
+    for i, c := range chunks {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            reviews[i], errs[i] = e.reviewWithPool(pool, c)
+        }()
+    }
+    wg.Wait()
+
+    failed := 0
+    for _, err := range errs {
+        if err != nil {
+            failed++
+        }
+    }
+    if failed == len(chunks) {
+        return errs[0]
+    }
+    if failed > 0 {
+        color.Red("✖ %d of %d parts failed: %s\n", failed, len(chunks), d.FilePath)
+    }
+    return e.saveReview(e.mergeChunkReviews(d, reviews, errs))
+}
+
+// reviewWithPool 占用并发槽位审查单个文件；自适应模式下被限流时让出槽位，退避后重新排队
+func (e *Engine) reviewWithPool(pool *workerPool, d gitDiff) (*fileReview, error) {
+    attempts := 0
+    for requeue := 0; ; requeue++ {
+        ticket := pool.acquire()
+        review, err := e.reviewSingleFile(d)
+        pool.release(ticket, err)
+        attempts += attemptsOf(err)
+        if err == nil || !e.cfg.AdaptivePool || !isOverloaded(err) || requeue >= maxThrottleRequeue {
+            if err != nil && requeue > 0 {
+                err = &modelCallError{attempts: attempts, err: err}
+            }
+            return review, err
+        }
+        // 立即重新排队会在限流窗口内再次触发 429，先按服务端建议或指数退避等待
+        delay := retryAfterOf(err)
+        if delay <= 0 {
+            delay = backoffDelay(requeue + 1)
+        }
+        color.Yellow("↻ throttled, requeue in %v: %s\n", delay.Round(100*time.Millisecond), d.FilePath)
+        select {
+        case <-time.After(delay):
+        case <-e.ctx.Done():
+            return nil, &modelCallError{attempts: attempts, err: e.ctx.Err()}
+        }
+    }
 }
@@ -0,0 +1,115 @@
+package reviewer
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    config "stellarspec/internal/model/conf"
+
+    "github.com/cloudwego/eino/components/model"
+    "github.com/cloudwego/eino/schema"
+    "github.com/fatih/color"
+)
+
+// 模型不可用（超时、限流、5xx、网络错误）后暂停使用的时长，期间请求直接交给回退链中的下一个模型
+const modelCooldown = time.Minute
+
+// chatModelEntry 回退链中的一个模型
+type chatModelEntry struct {
+    name  string
+    model model.BaseChatModel
+    // 不可用时记录恢复时间
+    downUntil time.Time
+}
+
+// newModelChain 按配置顺序创建主模型与回退模型
+func newModelChain(ctx context.Context, conf *config.BaseConfig) ([]*chatModelEntry, error) {
+    confs := append([]config.BaseConfig{*conf}, conf.Fallbacks...)
+    chain := make([]*chatModelEntry, 0, len(confs))
+    for i := range confs {
+        cm, err := newChatModel(ctx, &confs[i])
+        if err != nil {
+            return nil, fmt.Errorf("create model %s failed: %w", modelName(&confs[i]), err)
+        }
+        chain = append(chain, &chatModelEntry{name: modelName(&confs[i]), model: cm})
+    }
+    return chain, nil
+}
+
+// modelName 报告中记录的模型名，非 OpenAI 兼容接口带上服务提供方前缀
+func modelName(conf *config.BaseConfig) string {
+    provider := strings.ToLower(conf.Provider)
+    if provider == "" || provider == ProviderOpenAI {
+        return conf.Model
+    }
+    return provider + "/" + conf.Model
+}
+
+// invoke 依次在回退链中的模型上调用，返回结果与实际产出结果的模型名
+//
+// 每个模型先按 --retries 重试；仍失败（含超出上下文长度等不可重试的错误）时换下一个模型。
+// 不可用的模型在冷却期内排到链尾，避免每个文件都先等主模型超时
+func (e *Engine) invoke(input map[string]any, name string) (*schema.Message, string, error) {
+    if len(e.models) == 0 {
+        return nil, "", errors.New("chat model is nil")
+    }
+    chain := e.modelOrder()
+    attempts := 0
+    var lastErr error
+    for i, m := range chain {
+        r, err := e.compileGraph(m.model)
+        if err != nil {
+            return nil, "", err
+        }
+        ret, err := e.callModel(r, input, name)
+        if err == nil {
+            if m != e.models[0] {
+                color.Yellow("⇢ %s reviewed by fallback model %s\n", name, m.name)
+            }
+            return ret, m.name, nil
+        }
+        attempts += attemptsOf(err)
+        lastErr = err
+        if e.ctx.Err() != nil {
+            break
+        }
+        if errors.Is(err, errRequestTimeout) || isOverloaded(err) || e.retryable(err) {
+            e.markModelDown(m)
+        }
+        if i+1 < len(chain) {
+            color.Yellow("⇢ %s failed on %s, fall back to %s: err=%v\n", name, m.name, chain[i+1].name, err)
+        }
+    }
+    if len(e.models) == 1 {
+        return nil, "", lastErr
+    }
+    return nil, "", &modelCallError{attempts: attempts, err: fmt.Errorf("all %d models failed, last: %w", len(chain), lastErr)}
+}
+
+// modelOrder 可用的模型按配置顺序在前，冷却中的模型在后
+func (e *Engine) modelOrder() []*chatModelEntry {
+    e.modelsMu.Lock()
+    defer e.modelsMu.Unlock()
+    now := time.Now()
+    var up, down []*chatModelEntry
+    for _, m := range e.models {
+        if now.Before(m.downUntil) {
+            down = append(down, m)
+        } else {
+            up = append(up, m)
+        }
+    }
+    return append(up, down...)
+}
+
+func (e *Engine) markModelDown(m *chatModelEntry) {
+    if len(e.models) == 1 {
+        return
+    }
+    e.modelsMu.Lock()
+    defer e.modelsMu.Unlock()
+    m.downUntil = time.Now().Add(modelCooldown)
+}
@@ -0,0 +1,212 @@
+package reviewer
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Severity 问题严重程度
+type Severity string
+
+const (
+    SeverityCritical Severity = "critical"
+    SeverityMajor    Severity = "major"
+    SeverityMinor    Severity = "minor"
+    SeverityInfo     Severity = "info"
+)
+
+// 模型常用的同义词，统一归一到四个级别
+var severityAliases = map[string]Severity{
+    "critical": SeverityCritical,
+    "blocker":  SeverityCritical,
+    "major":    SeverityMajor,
+    "high":     SeverityMajor,
+    "error":    SeverityMajor,
+    "minor":    SeverityMinor,
+    "medium":   SeverityMinor,
+    "low":      SeverityMinor,
+    "warning":  SeverityMinor,
+    "info":     SeverityInfo,
+    "note":     SeverityInfo,
+    "nit":      SeverityInfo,
+}
+
+// ParseSeverity 解析严重程度，大小写不敏感
+func ParseSeverity(s string) (Severity, error) {
+    if sev, ok := severityAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+        return sev, nil
+    }
+    return "", fmt.Errorf("unknown severity: %q (expect critical/major/minor/info)", s)
+}
+
+// rank 数值越大越严重
+func (s Severity) rank() int {
+    switch s {
+    case SeverityCritical:
+        return 4
+    case SeverityMajor:
+        return 3
+    case SeverityMinor:
+        return 2
+    case SeverityInfo:
+        return 1
+    }
+    return 0
+}
+
+// Finding 单条审查发现
+type Finding struct {
+    File       string   `json:"file"`
+    StartLine  int      `json:"start_line"`
+    EndLine    int      `json:"end_line"`
+    Severity   Severity `json:"severity"`
+    Category   string   `json:"category"`
+    Message    string   `json:"message"`
+    Suggestion string   `json:"suggestion"`
+}
+
+// fileReview 单个文件的审查结果
+type fileReview struct {
+    FilePath string
+    // 重命名前的路径
+    OldPath  string
+    Summary  string
+    Findings []Finding
+    // 模型输出无法解析为结构化结果时保留原始文本
+    Raw string
+    // 推理模型的思考过程（--thinking-chain）
+    Reasoning string
+    // 未发送给模型的原因（二进制、超过大小上限）
+    Skipped string
+    // 重试后仍失败时的尝试次数与最终错误
+    Failed string
+    // 拆分审查时失败的部分数，其余部分的结果仍然保留
+    FailedParts int
+    // 产出结果的模型，启用回退链时可能不是主模型
+    Model string
+    // 审查完成（结果保存）的时间
+    ReviewedAt time.Time
+    // 批量审查时模型给出的逐文件总结
+    fileSummaries map[string]string
+}
+
+// structured 是否为结构化结果
+func (r *fileReview) structured() bool {
+    return r.Raw == "" && r.Skipped == "" && r.Failed == ""
+}
+
+// reviewOutput 要求模型输出的 JSON 结构
+type reviewOutput struct {
+    Summary string `json:"summary"`
+    // 批量审查时的逐文件总结
+    Files []struct {
+        File    string `json:"file"`
+        Summary string `json:"summary"`
+    } `json:"files"`
+    Findings []struct {
+        File       string `json:"file"`
+        StartLine  int    `json:"start_line"`
+        EndLine    int    `json:"end_line"`
+        Severity   string `json:"severity"`
+        Category   string `json:"category"`
+        Message    string `json:"message"`
+        Suggestion string `json:"suggestion"`
+    } `json:"findings"`
+}
+
+// parseFindings 解析并校验模型输出的 JSON，file 缺省时使用 filePath；filePath 为空（批量审查）时 file 必填
+func parseFindings(content, filePath string) (*fileReview, error) {
+    raw := extractJSON(content)
+    if raw == "" {
+        return nil, errors.New("no JSON object found in output")
+    }
+    var out reviewOutput
+    if err := json.Unmarshal([]byte(raw), &out); err != nil {
+        return nil, fmt.Errorf("invalid JSON: %v", err)
+    }
+
+    review := &fileReview{FilePath: filePath, Summary: strings.TrimSpace(out.Summary)}
+    for i, f := range out.Findings {
+        sev, err := ParseSeverity(f.Severity)
+        if err != nil {
+            return nil, fmt.Errorf("findings[%d]: %v", i, err)
+        }
+        if strings.TrimSpace(f.Message) == "" {
+            return nil, fmt.Errorf("findings[%d]: message is required", i)
+        }
+        if filePath == "" && strings.TrimSpace(f.File) == "" {
+            return nil, fmt.Errorf("findings[%d]: file is required", i)
+        }
+        if f.StartLine < 0 || f.EndLine < 0 {
+            return nil, fmt.Errorf("findings[%d]: line numbers must not be negative", i)
+        }
+        finding := Finding{
+            File:       f.File,
+            StartLine:  f.StartLine,
+            EndLine:    f.EndLine,
+            Severity:   sev,
+            Category:   strings.ToLower(strings.TrimSpace(f.Category)),
+            Message:    strings.TrimSpace(f.Message),
+            Suggestion: strings.TrimSpace(f.Suggestion),
+        }
+        if finding.File == "" {
+            finding.File = filePath
+        }
+        if finding.EndLine < finding.StartLine {
+            finding.EndLine = finding.StartLine
+        }
+        review.Findings = append(review.Findings, finding)
+    }
+    for _, f := range out.Files {
+        if review.fileSummaries == nil {
+            review.fileSummaries = map[string]string{}
+        }
+        review.fileSummaries[strings.TrimPrefix(f.File, "./")] = strings.TrimSpace(f.Summary)
+    }
+    return review, nil
+}
+
+// extractJSON 去掉 ```json 代码块等包裹，截取最外层的 JSON 对象
+func extractJSON(content string) string {
+    start := strings.IndexByte(content, '{')
+    end := strings.LastIndexByte(content, '}')
+    if start < 0 || end < start {
+        return ""
+    }
+    return content[start : end+1]
+}
+
+// findingsInstruction 输出格式约定，附加在内置或自定义 prompt 之后
+func findingsInstruction(language string) string {
+    if language == "en" {
+        return `Respond with a single JSON object only, without any other text, in the following format:
+{"summary": "one or two sentences on the overall quality of the change", "findings": [{"file": "file path", "start_line": 12, "end_line": 15, "severity": "critical|major|minor|info", "category": "bug|security|performance|concurrency|error-handling|maintainability|style|test", "message": "what the problem is and why", "suggestion": "how to fix it, code allowed"}]}
+Line numbers are new-file line numbers (use 0 when not applicable). Return an empty findings array when there is nothing to report. Write summary, message and suggestion in English.`
+    }
+    return `只输出一个 JSON 对象，不要包含任何其他文字，格式如下：
+{"summary": "用一两句话总结本次变更的整体质量", "findings": [{"file": "文件路径", "start_line": 12, "end_line": 15, "severity": "critical|major|minor|info", "category": "bug|security|performance|concurrency|error-handling|maintainability|style|test", "message": "问题是什么以及原因", "suggestion": "修改方案，可包含代码"}]}
+行号为新文件中的行号（不适用时填 0）。没有问题时 findings 返回空数组。summary、message、suggestion 使用中文。`
+}
+
+// batchFindingsInstruction 批量审查的输出格式约定：每个文件一条总结，每条问题必须带 file
+func batchFindingsInstruction(language string) string {
+    if language == "en" {
+        return `The user message contains changes to several files, each starting with a "=== path (change type) ===" line. Respond with a single JSON object only, without any other text, in the following format:
+{"summary": "one or two sentences on the change as a whole", "files": [{"file": "file path", "summary": "one or two sentences on this file"}], "findings": [{"file": "file path", "start_line": 12, "end_line": 15, "severity": "critical|major|minor|info", "category": "bug|security|performance|concurrency|error-handling|maintainability|style|test", "message": "what the problem is and why", "suggestion": "how to fix it, code allowed"}]}
+Give one entry in files for every file. The file of each finding must be exactly one of the paths in the "===" lines. Line numbers are new-file line numbers (use 0 when not applicable). Return an empty findings array when there is nothing to report. Write all summaries, message and suggestion in English.`
+    }
+    return `用户消息中包含多个文件的变更，每个文件以 "=== 路径 (变更类型) ===" 行开始。只输出一个 JSON 对象，不要包含任何其他文字，格式如下：
+{"summary": "用一两句话总结本次变更的整体质量", "files": [{"file": "文件路径", "summary": "用一两句话总结该文件的变更"}], "findings": [{"file": "文件路径", "start_line": 12, "end_line": 15, "severity": "critical|major|minor|info", "category": "bug|security|performance|concurrency|error-handling|maintainability|style|test", "message": "问题是什么以及原因", "suggestion": "修改方案，可包含代码"}]}
+files 中每个文件给出一条。每条问题的 file 必须是 "===" 行中的某个路径。行号为新文件中的行号（不适用时填 0）。没有问题时 findings 返回空数组。所有总结、message、suggestion 使用中文。`
+}
+
+// retryFormatMessage 模型输出不合法时追加的纠正消息
+func retryFormatMessage(language string, err error) string {
+    if language == "en" {
+        return fmt.Sprintf("Your previous output could not be parsed (%v). Respond again with only the JSON object in the required format.", err)
+    }
+    return fmt.Sprintf("你上一次的输出无法解析（%v），请只输出符合要求格式的 JSON 对象。", err)
+}
@@ -0,0 +1,82 @@
+package reviewer
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParseFindings(t *testing.T) {
+    content := "```json\n" + `{"summary": " ok ", "findings": [
+        {"start_line": 12, "end_line": 10, "severity": "HIGH", "category": " Bug ", "message": " nil deref ", "suggestion": " check err "},
+        {"file": "other.go", "start_line": 3, "severity": "nit", "category": "style", "message": "naming"}
+    ]}` + "\n```"
+    review, err := parseFindings(content, "pkg/a.go")
+    if err != nil {
+        t.Fatalf("parseFindings() error: %v", err)
+    }
+    if review.FilePath != "pkg/a.go" || review.Summary != "ok" {
+        t.Errorf("review = {FilePath: %q, Summary: %q}", review.FilePath, review.Summary)
+    }
+    want := []Finding{
+        {File: "pkg/a.go", StartLine: 12, EndLine: 12, Severity: SeverityMajor, Category: "bug", Message: "nil deref", Suggestion: "check err"},
+        {File: "other.go", StartLine: 3, EndLine: 3, Severity: SeverityInfo, Category: "style", Message: "naming"},
+    }
+    if !reflect.DeepEqual(review.Findings, want) {
+        t.Errorf("findings =\n%+v\nwant\n%+v", review.Findings, want)
+    }
+}
+
+func TestParseFindingsBatchSummaries(t *testing.T) {
+    content := `{"summary": "s", "files": [{"file": "./a.go", "summary": " fine "}], "findings": []}`
+    review, err := parseFindings(content, "")
+    if err != nil {
+        t.Fatalf("parseFindings() error: %v", err)
+    }
+    if got := review.fileSummaries["a.go"]; got != "fine" {
+        t.Errorf("fileSummaries[a.go] = %q, want %q", got, "fine")
+    }
+}
+
+func TestParseFindingsInvalid(t *testing.T) {
+    tests := []struct {
+        name     string
+        content  string
+        filePath string
+        wantErr  string
+    }{
+        {"no json", "looks good to me", "a.go", "no JSON object"},
+        {"malformed", `{"summary": }`, "a.go", "invalid JSON"},
+        {"unknown severity", `{"findings": [{"severity": "urgent", "message": "m"}]}`, "a.go", "unknown severity"},
+        {"empty message", `{"findings": [{"severity": "minor", "message": "  "}]}`, "a.go", "message is required"},
+        {"batch without file", `{"findings": [{"severity": "minor", "message": "m"}]}`, "", "file is required"},
+        {"negative line", `{"findings": [{"severity": "minor", "message": "m", "start_line": -1}]}`, "a.go", "must not be negative"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := parseFindings(tt.content, tt.filePath)
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("parseFindings() error = %v, want containing %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestParseSeverity(t *testing.T) {
+    tests := map[string]Severity{
+        "critical": SeverityCritical,
+        " Blocker": SeverityCritical,
+        "ERROR":    SeverityMajor,
+        "medium":   SeverityMinor,
+        "warning":  SeverityMinor,
+        "note":     SeverityInfo,
+    }
+    for in, want := range tests {
+        if got, err := ParseSeverity(in); err != nil || got != want {
+            t.Errorf("ParseSeverity(%q) = %q, %v, want %q", in, got, err, want)
+        }
+    }
+    if _, err := ParseSeverity("urgent"); err == nil {
+        t.Error("ParseSeverity(\"urgent\") should fail")
+    }
+}
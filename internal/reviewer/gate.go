@@ -0,0 +1,82 @@
+package reviewer
+
+import (
+    "fmt"
+    "strings"
+)
+
+// FindingsError 报告中存在达到 --fail-on 等级的问题，供 CI 阻止合入
+type FindingsError struct {
+    Threshold Severity
+    Count     int // 达到等级的问题数
+    Files     int // 涉及的文件数
+}
+
+func (e *FindingsError) Error() string {
+    return fmt.Sprintf("%d findings at or above %s in %d files", e.Count, e.Threshold, e.Files)
+}
+
+// ReviewFailedError 审查结果不完整，无法据此判断是否达到 --fail-on 等级：
+// 有文件重试与回退后仍审查失败、只有部分拆分成功，或（设置了 --fail-on 时）模型未按结构化格式输出
+type ReviewFailedError struct {
+    Failed       int // 整个文件审查失败
+    Partial      int // 部分拆分审查失败
+    Unstructured int // 非结构化结果，无法判断严重程度
+    Total        int
+}
+
+func (e *ReviewFailedError) Error() string {
+    var parts []string
+    if e.Failed > 0 {
+        parts = append(parts, fmt.Sprintf("%d failed to review", e.Failed))
+    }
+    if e.Partial > 0 {
+        parts = append(parts, fmt.Sprintf("%d partially failed to review", e.Partial))
+    }
+    if e.Unstructured > 0 {
+        parts = append(parts, fmt.Sprintf("%d have unstructured reviews without severity", e.Unstructured))
+    }
+    return fmt.Sprintf("incomplete review of %d files: %s", e.Total, strings.Join(parts, ", "))
+}
+
+// checkResults 在报告写入后检查审查结果：结果不完整时优先返回 ReviewFailedError，
+// 否则在设置了 --fail-on 且存在达到等级的问题时返回 FindingsError
+//
+// 非结构化结果无法判断严重程度，设置了 --fail-on 时视为不完整，避免模型未输出 JSON 时静默通过
+func (e *Engine) checkResults(reviews []*fileReview, threshold Severity) error {
+    incomplete := &ReviewFailedError{Total: len(reviews)}
+    for _, r := range reviews {
+        switch {
+        case r.Failed != "":
+            incomplete.Failed++
+        case r.FailedParts > 0:
+            incomplete.Partial++
+        case threshold != "" && r.Raw != "":
+            incomplete.Unstructured++
+        }
+    }
+    if incomplete.Failed+incomplete.Partial+incomplete.Unstructured > 0 {
+        return incomplete
+    }
+    if threshold == "" {
+        return nil
+    }
+
+    count, files := 0, 0
+    for _, r := range reviews {
+        n := 0
+        for _, f := range r.Findings {
+            if f.Severity.rank() >= threshold.rank() {
+                n++
+            }
+        }
+        if n > 0 {
+            count += n
+            files++
+        }
+    }
+    if count > 0 {
+        return &FindingsError{Threshold: threshold, Count: count, Files: files}
+    }
+    return nil
+}
@@ -0,0 +1,128 @@
+package reviewer
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ignoreFileName 仓库根目录下的忽略规则文件，语法与 .gitignore 相同
+const ignoreFileName = ".stellarignore"
+
+// defaultIgnorePatterns 默认不审查的文件：依赖锁文件、vendor 目录、压缩产物
+var defaultIgnorePatterns = []string{
+    "go.sum",
+    "package-lock.json",
+    "yarn.lock",
+    "pnpm-lock.yaml",
+    "Cargo.lock",
+    "Gemfile.lock",
+    "composer.lock",
+    "poetry.lock",
+    "Pipfile.lock",
+    "vendor/",
+    "node_modules/",
+    "*.min.js",
+    "*.min.css",
+}
+
+// 生成代码的标记：Go 约定的 "Code generated ... DO NOT EDIT." 以及通用的 @generated
+var generatedPattern = regexp.MustCompile(`(?m)^\s*(//|#|--|/?\*)?\s*(Code generated .* DO NOT EDIT\.?|@generated\b)`)
+
+// 只检查文件开头部分
+const generatedHeaderSize = 4096
+
+// ignoreRules 组合默认规则、.stellarignore 与 --include/--exclude
+//
+// 规则按 默认 -> .stellarignore -> --exclude -> --include 的顺序排列，
+// 与 gitignore 一样后出现的规则优先，因此 .stellarignore 中的 !go.sum 可以覆盖默认规则，
+// --include 可以覆盖其他所有规则
+type ignoreRules struct {
+    matcher  gitignore.Matcher
+    includes gitignore.Matcher // 仅由 --include 组成，命中时跳过生成代码检测
+    // 是否检测生成代码
+    detectGenerated bool
+}
+
+// loadIgnoreRules 读取仓库根目录下的 .stellarignore 并与命令行规则合并
+func (e *Engine) loadIgnoreRules() (*ignoreRules, error) {
+    var patterns []gitignore.Pattern
+    add := func(lines ...string) {
+        for _, l := range lines {
+            l = strings.TrimRight(l, " \t\r")
+            if l == "" || strings.HasPrefix(l, "#") {
+                continue
+            }
+            patterns = append(patterns, gitignore.ParsePattern(l, nil))
+        }
+    }
+
+    // 报告文件位于仓库内时，避免下次运行时被当作未追踪文件审查
+    if p := e.outputIgnorePattern(); p != "" {
+        add(p)
+    }
+    if !e.cfg.NoDefaultIgnore {
+        add(defaultIgnorePatterns...)
+    }
+
+    lines, err := readIgnoreFile(filepath.Join(e.repoRoot, ignoreFileName))
+    if err != nil {
+        return nil, err
+    }
+    add(lines...)
+    add(e.cfg.Excludes...)
+
+    var includes []gitignore.Pattern
+    for _, p := range e.cfg.Includes {
+        add("!" + p)
+        includes = append(includes, gitignore.ParsePattern(p, nil))
+    }
+
+    return &ignoreRules{
+        matcher:         gitignore.NewMatcher(patterns),
+        includes:        gitignore.NewMatcher(includes),
+        detectGenerated: !e.cfg.NoDefaultIgnore,
+    }, nil
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("open %s failed: %v", ignoreFileName, err)
+    }
+    defer f.Close()
+
+    var lines []string
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        lines = append(lines, scanner.Text())
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("read %s failed: %v", ignoreFileName, err)
+    }
+    return lines, nil
+}
+
+// ignored file 为相对仓库根目录、以 / 分隔的路径
+func (r *ignoreRules) ignored(file string) bool {
+    return r.matcher.Match(strings.Split(file, "/"), false)
+}
+
+// generated 判断文件是否为生成代码；被 --include 显式包含的文件不做检测
+func (r *ignoreRules) generated(file, content string) bool {
+    if !r.detectGenerated || r.includes.Match(strings.Split(file, "/"), false) {
+        return false
+    }
+    if len(content) > generatedHeaderSize {
+        content = content[:generatedHeaderSize]
+    }
+    return generatedPattern.MatchString(content)
+}
@@ -2,17 +2,98 @@ package reviewer
 
 import (
     "context"
+    "errors"
+    "fmt"
+    "regexp"
     config "stellarspec/internal/model/conf"
+    "strconv"
+    "strings"
 
+    "github.com/cloudwego/eino-ext/components/model/claude"
+    "github.com/cloudwego/eino-ext/components/model/ollama"
     "github.com/cloudwego/eino-ext/components/model/openai"
+    "github.com/cloudwego/eino/components/model"
 )
 
-// newChatModel 创建底层 OpenAI ChatModel
-func newChatModel(ctx context.Context, conf *config.BaseConfig) (*openai.ChatModel, error) {
-    modelConf := &openai.ChatModelConfig{
-        APIKey:  conf.Key,
-        BaseURL: conf.APIServer,
-        Model:   conf.Model,
+// 配置项 Provider 的取值
+const (
+    ProviderOpenAI    = "openai"    // OpenAI 及兼容接口（DeepSeek、vLLM 等），默认
+    ProviderOllama    = "ollama"    // 本地或自建的 Ollama
+    ProviderAnthropic = "anthropic" // Anthropic Messages API
+    ProviderAzure     = "azure"     // Azure OpenAI，Model 为部署名
+)
+
+const (
+    // Ollama 未配置 APIServer 时的默认地址
+    defaultOllamaServer = "http://localhost:11434"
+    // Azure OpenAI 未配置 APIVersion 时使用的版本
+    defaultAzureAPIVersion = "2024-06-01"
+    // Anthropic Messages API 必须指定输出 token 上限
+    anthropicMaxTokens = 8192
+)
+
+// newChatModel 根据 Provider 创建底层 ChatModel，为空时使用 OpenAI 兼容接口
+func newChatModel(ctx context.Context, conf *config.BaseConfig) (model.BaseChatModel, error) {
+    switch provider := strings.ToLower(conf.Provider); provider {
+    case "", ProviderOpenAI:
+        return openai.NewChatModel(ctx, &openai.ChatModelConfig{
+            APIKey:  conf.Key,
+            BaseURL: conf.APIServer,
+            Model:   conf.Model,
+        })
+    case ProviderAzure:
+        apiVersion := conf.APIVersion
+        if apiVersion == "" {
+            apiVersion = defaultAzureAPIVersion
+        }
+        return openai.NewChatModel(ctx, &openai.ChatModelConfig{
+            APIKey:     conf.Key,
+            BaseURL:    conf.APIServer,
+            Model:      conf.Model,
+            ByAzure:    true,
+            APIVersion: apiVersion,
+        })
+    case ProviderOllama:
+        server := conf.APIServer
+        if server == "" {
+            server = defaultOllamaServer
+        }
+        return ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
+            BaseURL: server,
+            Model:   conf.Model,
+        })
+    case ProviderAnthropic:
+        modelConf := &claude.Config{
+            APIKey:    conf.Key,
+            Model:     conf.Model,
+            MaxTokens: anthropicMaxTokens,
+        }
+        if conf.APIServer != "" {
+            modelConf.BaseURL = &conf.APIServer
+        }
+        return claude.NewChatModel(ctx, modelConf)
+    default:
+        return nil, fmt.Errorf("unsupported provider: %s (expect %s, %s, %s or %s)", provider, ProviderOpenAI, ProviderOllama, ProviderAnthropic, ProviderAzure)
+    }
+}
+
+// 模型客户端错误信息中的 HTTP 状态码：OpenAI 形如 "status code: 429"，
+// Anthropic 形如 `POST "https://...": 429 Too Many Requests`，Ollama 以 "429 Too Many Requests" 开头
+var statusCodePattern = regexp.MustCompile(`(?:status code: |^|": )(\d{3})\b`)
+
+// statusCodeOf 从模型调用错误中提取 HTTP 状态码，提取不到时返回 0
+func statusCodeOf(err error) int {
+    for ; err != nil; err = errors.Unwrap(err) {
+        if m := statusCodePattern.FindStringSubmatch(err.Error()); m != nil {
+            code, _ := strconv.Atoi(m[1])
+            return code
+        }
     }
-    return openai.NewChatModel(ctx, modelConf)
+    return 0
+}
+
+// isOverloaded 判断是否为限流（429）或服务端错误（5xx）
+func isOverloaded(err error) bool {
+    code := statusCodeOf(err)
+    return code == 429 || code >= 500
 }
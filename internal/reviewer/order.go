@@ -0,0 +1,70 @@
+package reviewer
+
+import (
+    "fmt"
+    "sort"
+)
+
+// 报告中文件的排列顺序
+const (
+    SortByPath     = "path"     // 按文件路径（默认）
+    SortBySeverity = "severity" // 按文件中最严重的问题，严重的在前
+)
+
+func parseSortBy(by string) (string, error) {
+    switch by {
+    case "":
+        return SortByPath, nil
+    case SortByPath, SortBySeverity:
+        return by, nil
+    default:
+        return "", fmt.Errorf("unsupported sort: %s (expect %s or %s)", by, SortByPath, SortBySeverity)
+    }
+}
+
+// sortReviews 对文件及每个文件内的问题排序，使报告不依赖审查完成的先后
+//
+// severity 顺序下，失败的文件排在最前，其次按最严重问题的等级，跳过的文件在最后；
+// 等级相同时按路径排序
+func sortReviews(reviews []*fileReview, by string) {
+    for _, r := range reviews {
+        sortFindings(r.Findings, by)
+    }
+    sort.SliceStable(reviews, func(i, j int) bool {
+        if by == SortBySeverity {
+            if ri, rj := reviewRank(reviews[i]), reviewRank(reviews[j]); ri != rj {
+                return ri > rj
+            }
+        }
+        return reviews[i].FilePath < reviews[j].FilePath
+    })
+}
+
+// sortFindings 按位置排序；severity 顺序下先按等级
+func sortFindings(findings []Finding, by string) {
+    sort.SliceStable(findings, func(i, j int) bool {
+        a, b := findings[i], findings[j]
+        if by == SortBySeverity && a.Severity.rank() != b.Severity.rank() {
+            return a.Severity.rank() > b.Severity.rank()
+        }
+        if a.File != b.File {
+            return a.File < b.File
+        }
+        return a.StartLine < b.StartLine
+    })
+}
+
+// reviewRank 文件在 severity 顺序下的等级，数值越大越靠前
+func reviewRank(r *fileReview) int {
+    switch {
+    case r.Failed != "":
+        return SeverityCritical.rank() + 1
+    case r.Skipped != "":
+        return -1
+    }
+    rank := 0
+    for _, f := range r.Findings {
+        rank = max(rank, f.Severity.rank())
+    }
+    return rank
+}
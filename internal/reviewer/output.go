@@ -0,0 +1,176 @@
+package reviewer
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/fatih/color"
+)
+
+// 报告写入方式
+const (
+    OutputOverwrite   = "overwrite"   // 覆盖上次的报告（默认）
+    OutputAppend      = "append"      // 追加到已有报告之后
+    OutputTimestamped = "timestamped" // 每次运行写入带时间戳的新文件
+)
+
+// 时间戳文件名中的时间格式，如 code-review-20061017-150405.md
+const outputStampLayout = "20060102-150405"
+
+func parseOutputMode(mode string) (string, error) {
+    switch mode {
+    case "":
+        return OutputOverwrite, nil
+    case OutputOverwrite, OutputAppend, OutputTimestamped:
+        return mode, nil
+    default:
+        return "", fmt.Errorf("unsupported output mode: %s (expect %s, %s or %s)", mode, OutputOverwrite, OutputAppend, OutputTimestamped)
+    }
+}
+
+// outputBase 未加时间戳的报告路径；相对路径以仓库根目录为基准
+func (e *Engine) outputBase() (string, error) {
+    output := e.cfg.OutputFile
+    if output == "" {
+        output = "code-review.md"
+        if e.cfg.Format == FormatSARIF {
+            output = "code-review.sarif"
+        }
+    }
+    if filepath.IsAbs(output) {
+        return output, nil
+    }
+    workDir, err := e.getWorkPath()
+    if err != nil {
+        return "", fmt.Errorf("failed to get work path: %v", err)
+    }
+    return filepath.Join(workDir, output), nil
+}
+
+// outputPath 本次运行的报告路径，timestamped 模式在扩展名前插入运行开始时间
+func (e *Engine) outputPath() (string, error) {
+    base, err := e.outputBase()
+    if err != nil {
+        return "", err
+    }
+    if e.cfg.OutputMode != OutputTimestamped {
+        return base, nil
+    }
+    ext := filepath.Ext(base)
+    return strings.TrimSuffix(base, ext) + "-" + e.started.Format(outputStampLayout) + ext, nil
+}
+
+// outputIgnorePattern 报告文件位于仓库内时返回对应的忽略规则，timestamped 模式匹配所有历史报告
+func (e *Engine) outputIgnorePattern() string {
+    base, err := e.outputBase()
+    if err != nil {
+        return ""
+    }
+    rel, err := filepath.Rel(e.repoRoot, base)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return ""
+    }
+    if e.cfg.OutputMode == OutputTimestamped {
+        ext := filepath.Ext(rel)
+        rel = strings.TrimSuffix(rel, ext) + "-*" + ext
+    }
+    return "/" + filepath.ToSlash(rel)
+}
+
+// writeReport 按写入方式输出报告；merge 将已有内容与本次内容合并（仅 append 模式且文件已存在时调用）
+func (e *Engine) writeReport(data []byte, merge func(old []byte) ([]byte, error)) error {
+    path, err := e.outputPath()
+    if err != nil {
+        return err
+    }
+    if e.cfg.OutputMode == OutputAppend {
+        old, err := os.ReadFile(path)
+        switch {
+        case err == nil && len(old) > 0:
+            if data, err = merge(old); err != nil {
+                return err
+            }
+        case err != nil && !os.IsNotExist(err):
+            return fmt.Errorf("failed to read output file: %v", err)
+        }
+    }
+    if err := writeFileAtomic(path, data); err != nil {
+        return err
+    }
+    color.Green("✔ report: %s\n", path)
+    return nil
+}
+
+// writeFileAtomic 先写同目录下的临时文件再重命名，中途失败、被中断或并发读取时不会看到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+    dir := filepath.Dir(path)
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return fmt.Errorf("failed to create dir: path=%s, err=%v", dir, err)
+    }
+    tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return fmt.Errorf("failed to create temp file: path=%s, err=%v", path, err)
+    }
+    _, werr := tmp.Write(data)
+    cerr := tmp.Close()
+    if werr == nil && cerr == nil {
+        // CreateTemp 创建的文件权限为 0600
+        werr = os.Chmod(tmp.Name(), 0644)
+    }
+    if werr != nil || cerr != nil {
+        os.Remove(tmp.Name())
+        return fmt.Errorf("failed to write file: path=%s, err=%v", path, errors.Join(werr, cerr))
+    }
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        os.Remove(tmp.Name())
+        return fmt.Errorf("failed to replace file: path=%s, err=%v", path, err)
+    }
+    return nil
+}
+
+// runInfo 报告头中记录的本次运行信息
+type runInfo struct {
+    Repository string    `json:"repository"`
+    Target     string    `json:"target"`
+    Models     []string  `json:"models"`
+    StartedAt  time.Time `json:"startedAt"`
+}
+
+func (e *Engine) runInfo() runInfo {
+    names := make([]string, 0, len(e.models))
+    for _, m := range e.models {
+        names = append(names, m.name)
+    }
+    return runInfo{Repository: e.repoRoot, Target: e.target, Models: names, StartedAt: e.started}
+}
+
+// formatRunHeader 报告开头的运行信息，append 模式下也用于分隔各次运行
+func (e *Engine) formatRunHeader() string {
+    info := e.runInfo()
+    models := strings.Join(info.Models, " → ")
+    started := info.StartedAt.Format("2006-01-02 15:04:05")
+    if e.cfg.Language == "en" {
+        return fmt.Sprintf(`# Code Review
+
+**Repository**: %s  
+**Target**: %s  
+**Model**: %s  
+**Started**: %s
+
+---
+`, info.Repository, info.Target, models, started)
+    }
+    return fmt.Sprintf(`# 代码审查
+
+**仓库**: %s  
+**审查范围**: %s  
+**模型**: %s  
+**开始时间**: %s
+
+---
+`, info.Repository, info.Target, models, started)
+}
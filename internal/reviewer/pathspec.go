@@ -0,0 +1,82 @@
+package reviewer
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// pathspec 限定审查范围的路径：目录前缀、单个文件或 glob
+//
+// 与 git 默认的 pathspec 一致，glob 中的 * 可以跨越目录分隔符，
+// 例如 internal/*.go 同时匹配 internal/a.go 与 internal/api/b.go
+type pathspec struct {
+    prefix string         // 仓库根目录下的相对路径，"" 表示整个仓库
+    glob   *regexp.Regexp // 非空时按 glob 匹配
+}
+
+// newPathspec 将命令行参数（相对当前目录或绝对路径）转换为相对仓库根目录的 pathspec
+func newPathspec(repoRoot, arg string) (pathspec, error) {
+    abs, err := filepath.Abs(arg)
+    if err != nil {
+        return pathspec{}, fmt.Errorf("convert to abs path failed: path=%s, err=%v", arg, err)
+    }
+    rel, err := filepath.Rel(repoRoot, abs)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return pathspec{}, fmt.Errorf("path is outside repository: path=%s, repo=%s", arg, repoRoot)
+    }
+    rel = filepath.ToSlash(rel)
+    if rel == "." {
+        rel = ""
+    }
+    if !hasGlobMeta(rel) {
+        return pathspec{prefix: rel}, nil
+    }
+    re, err := globToRegexp(rel)
+    if err != nil {
+        return pathspec{}, fmt.Errorf("invalid glob: path=%s, err=%v", arg, err)
+    }
+    return pathspec{glob: re}, nil
+}
+
+// match file 为相对仓库根目录、以 / 分隔的路径
+func (p pathspec) match(file string) bool {
+    if p.glob != nil {
+        return p.glob.MatchString(file)
+    }
+    return p.prefix == "" || file == p.prefix || strings.HasPrefix(file, p.prefix+"/")
+}
+
+func hasGlobMeta(s string) bool {
+    return strings.ContainsAny(s, "*?[")
+}
+
+// globToRegexp 将 glob 转为正则：* 匹配任意字符（含 /），? 匹配单个字符，[...] 为字符集
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+    var sb strings.Builder
+    sb.WriteString("^")
+    for i := 0; i < len(glob); i++ {
+        switch c := glob[i]; c {
+        case '*':
+            sb.WriteString(".*")
+        case '?':
+            sb.WriteString(".")
+        case '[':
+            end := strings.IndexByte(glob[i+1:], ']')
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated character class")
+            }
+            class := glob[i+1 : i+1+end]
+            if strings.HasPrefix(class, "!") {
+                class = "^" + class[1:]
+            }
+            sb.WriteString("[" + class + "]")
+            i += end + 1
+        default:
+            sb.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    sb.WriteString("$")
+    return regexp.Compile(sb.String())
+}
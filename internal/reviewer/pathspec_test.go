@@ -0,0 +1,72 @@
+package reviewer
+
+import (
+    "path/filepath"
+    "testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+    tests := []struct {
+        glob  string
+        match []string
+        miss  []string
+    }{
+        {"internal/*.go", []string{"internal/a.go", "internal/api/b.go"}, []string{"internal/a.gox", "cmd/a.go"}},
+        {"*_test.go", []string{"a_test.go", "pkg/a_test.go"}, []string{"a_test.go.bak", "a.go"}},
+        {"cmd/?.go", []string{"cmd/a.go"}, []string{"cmd/ab.go", "cmd/.go"}},
+        {"v[12].txt", []string{"v1.txt", "v2.txt"}, []string{"v3.txt", "v12.txt"}},
+        {"v[!12].txt", []string{"v3.txt"}, []string{"v1.txt", "v2.txt"}},
+        {"a+b(c).go", []string{"a+b(c).go"}, []string{"aab(c).go", "a+bc.go"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.glob, func(t *testing.T) {
+            re, err := globToRegexp(tt.glob)
+            if err != nil {
+                t.Fatalf("globToRegexp(%q) error: %v", tt.glob, err)
+            }
+            for _, s := range tt.match {
+                if !re.MatchString(s) {
+                    t.Errorf("%q should match %q", tt.glob, s)
+                }
+            }
+            for _, s := range tt.miss {
+                if re.MatchString(s) {
+                    t.Errorf("%q should not match %q", tt.glob, s)
+                }
+            }
+        })
+    }
+}
+
+func TestGlobToRegexpUnterminatedClass(t *testing.T) {
+    if _, err := globToRegexp("a[bc"); err == nil {
+        t.Error("globToRegexp(\"a[bc\") should fail")
+    }
+}
+
+func TestPathspecMatch(t *testing.T) {
+    root := t.TempDir()
+    tests := []struct {
+        arg   string
+        file  string
+        match bool
+    }{
+        {".", "any/file.go", true},
+        {"internal", "internal/a.go", true},
+        {"internal", "internalx/a.go", false},
+        {"cmd/main.go", "cmd/main.go", true},
+        {"internal/*.go", "internal/api/b.go", true},
+    }
+    for _, tt := range tests {
+        ps, err := newPathspec(root, filepath.Join(root, tt.arg))
+        if err != nil {
+            t.Fatalf("newPathspec(%q) error: %v", tt.arg, err)
+        }
+        if got := ps.match(tt.file); got != tt.match {
+            t.Errorf("pathspec %q match(%q) = %v, want %v", tt.arg, tt.file, got, tt.match)
+        }
+    }
+    if _, err := newPathspec(root, filepath.Dir(root)); err == nil {
+        t.Error("newPathspec outside the repository should fail")
+    }
+}
@@ -0,0 +1,83 @@
+package reviewer
+
+import (
+    "sync"
+
+    "github.com/fatih/color"
+)
+
+const defaultMaxWorkers = 10
+
+// workerPool 控制并发审查数量
+//
+// 自适应模式下按 AIMD 调整上限：模型端返回 429/5xx 时减半，
+// 连续成功次数达到当前上限后加一，直到回到 max
+type workerPool struct {
+    mu   sync.Mutex
+    cond *sync.Cond
+
+    max      int
+    limit    int
+    active   int
+    adaptive bool
+
+    // 每次收缩后递增；收缩前就已发出的请求再失败不会重复收缩
+    gen       int
+    successes int
+}
+
+// poolTicket 记录占用槽位时的代数
+type poolTicket struct {
+    gen int
+}
+
+func newWorkerPool(size int, adaptive bool) *workerPool {
+    if size <= 0 {
+        size = defaultMaxWorkers
+    }
+    p := &workerPool{max: size, limit: size, adaptive: adaptive}
+    p.cond = sync.NewCond(&p.mu)
+    return p
+}
+
+// acquire 阻塞直到有空闲槽位
+func (p *workerPool) acquire() poolTicket {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for p.active >= p.limit {
+        p.cond.Wait()
+    }
+    p.active++
+    return poolTicket{gen: p.gen}
+}
+
+// release 归还槽位，并在自适应模式下根据本次调用结果调整上限
+func (p *workerPool) release(t poolTicket, err error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.active--
+    defer p.cond.Broadcast()
+
+    if !p.adaptive {
+        return
+    }
+    switch {
+    case err == nil:
+        p.successes++
+        if p.limit < p.max && p.successes >= p.limit {
+            p.limit++
+            p.successes = 0
+            color.Yellow("⇡ pool: %d/%d\n", p.limit, p.max)
+        }
+    case isOverloaded(err):
+        if t.gen != p.gen {
+            return
+        }
+        p.gen++
+        p.successes = 0
+        if p.limit > 1 {
+            p.limit = max(p.limit/2, 1)
+            color.Yellow("⇣ pool: %d/%d\n", p.limit, p.max)
+        }
+    }
+}
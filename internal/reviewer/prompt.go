@@ -0,0 +1,114 @@
+package reviewer
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "text/template"
+)
+
+// promptData 自定义 prompt 模板中可用的变量
+type promptData struct {
+    FilePath       string // 文件路径（相对仓库根目录）
+    OldPath        string // 重命名前的路径，仅 renamed 时有值
+    Ext            string // 扩展名，如 .go
+    Language       string // 由扩展名识别的语言，如 Go
+    ChangeType     string // 变更类型：added / modified / renamed / deleted
+    OutputLanguage string // 报告语言：zh / en
+    Context        string // --context 生成的上下文（hunk 所在函数或完整文件），可能为空
+
+    diff     string
+    diffUsed bool
+}
+
+// Diff 变更内容；模板中引用 {{.Diff}} 时，渲染结果将作为用户消息发送
+func (p *promptData) Diff() string {
+    p.diffUsed = true
+    return p.diff
+}
+
+// loadPromptTemplate 加载 --prompt-file 指定的模板，未指定时使用内置 prompt
+func (e *Engine) loadPromptTemplate() error {
+    if e.cfg.PromptPath == "" {
+        return nil
+    }
+    content, err := os.ReadFile(e.cfg.PromptPath)
+    if err != nil {
+        return fmt.Errorf("read prompt file failed: path=%s, err=%v", e.cfg.PromptPath, err)
+    }
+    tpl, err := template.New(filepath.Base(e.cfg.PromptPath)).Option("missingkey=error").Parse(string(content))
+    if err != nil {
+        return fmt.Errorf("parse prompt file failed: path=%s, err=%v", e.cfg.PromptPath, err)
+    }
+    e.promptTpl = tpl
+    return nil
+}
+
+// buildPrompt 生成单个文件的 system / user 消息；无论使用哪种 prompt，system 末尾都会附加结构化输出格式约定
+func (e *Engine) buildPrompt(d gitDiff) (system, user string, err error) {
+    system, user, err = e.renderPrompt(d)
+    if err != nil {
+        return "", "", err
+    }
+    instruction := findingsInstruction(e.cfg.Language)
+    if d.ChangeType == changeBatch {
+        instruction = batchFindingsInstruction(e.cfg.Language)
+    }
+    if system == "" {
+        return instruction, user, nil
+    }
+    return system + "\n\n" + instruction, user, nil
+}
+
+// renderPrompt 渲染内置 prompt 或 --prompt-file 模板，system 为空时表示模板已包含变更内容
+func (e *Engine) renderPrompt(d gitDiff) (system, user string, err error) {
+    ext, lang := filepath.Ext(d.FilePath), getFileLanguage(d.FilePath)
+    if d.ChangeType == changeBatch {
+        ext, lang = batchDistinct(d.Files, filepath.Ext), batchDistinct(d.Files, getFileLanguage)
+    }
+    context := e.fileContext(d) + e.symbolContext(d)
+    if e.promptTpl == nil {
+        if d.ChangeType == changeDeleted {
+            return deletedSystemPrompt(e.cfg.Language, ext), d.Content, nil
+        }
+        return defaultSystemPrompt(e.cfg.Language, ext), d.Content + context, nil
+    }
+
+    // 批量审查时 FilePath 为逐个文件路径以逗号连接
+    data := &promptData{
+        FilePath:       d.FilePath,
+        OldPath:        d.OldPath,
+        Ext:            ext,
+        Language:       lang,
+        ChangeType:     d.ChangeType,
+        OutputLanguage: e.cfg.Language,
+        Context:        context,
+        diff:           d.Content,
+    }
+    var buf bytes.Buffer
+    if err := e.promptTpl.Execute(&buf, data); err != nil {
+        return "", "", fmt.Errorf("render prompt failed: %w", err)
+    }
+    if data.diffUsed {
+        return "", buf.String(), nil
+    }
+    return buf.String(), d.Content + context, nil
+}
+
+// defaultSystemPrompt 内置的审查 prompt，根据语言设置选择
+func defaultSystemPrompt(language, ext string) string {
+    if language == "en" {
+        return fmt.Sprintf("You are a %s development expert. You will review the code changes provided by the user. Modified files are given as unified diffs: lines starting with + are added, lines starting with - are removed, and @@ hunk headers carry the line numbers; when citing code, use new-file line numbers. Point out the problems in the change together with your review suggestions and modification proposals, and keep each finding concise.", ext)
+    }
+    // 默认中文
+    return fmt.Sprintf("你是一位  %s 研发专家，现在你将对用户给出的代码变更内容进行 code review。修改的文件以 unified diff 形式给出：+ 开头为新增行，- 开头为删除行，@@ hunk 头中带有行号，引用代码时请使用新文件的行号。请指出变更中存在的问题，并给出评审建议与修改方案，每条问题保持简洁。", ext)
+}
+
+// deletedSystemPrompt 删除文件的轻量审查：只关注被删除的代码是否仍被引用
+func deletedSystemPrompt(language, ext string) string {
+    if language == "en" {
+        return fmt.Sprintf("You are a %s development expert. The user deleted a file. You are given the symbols it defined, the places in the repository that still reference them, and the beginning of the removed content. Judge whether the removed code is still referenced elsewhere and whether the deletion breaks callers; report each dangling reference as a finding located at the referencing file and line. Do not review the style of the removed code.", ext)
+    }
+    return fmt.Sprintf("你是一位  %s 研发专家，用户删除了一个文件。下面给出该文件中定义的符号、仓库中仍引用这些符号的位置以及被删除内容的开头部分。请判断被删除的代码是否仍在其他地方被引用、删除后是否会导致调用方出错；每处悬空引用作为一条问题输出，位置使用引用所在的文件与行号。无需评审被删除代码本身的风格。", ext)
+}
@@ -0,0 +1,108 @@
+package reviewer
+
+import (
+    "sort"
+
+    "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// renamePair 工作区中识别出的一对重命名
+type renamePair struct {
+    oldPath string
+    newPath string
+    score   int
+}
+
+// similarity 按行计算两段内容的相似度（0-100）：相同行的字节数占两侧总字节数的比例
+func similarity(oldContent, newContent string) int {
+    if oldContent == newContent {
+        return 100
+    }
+    total := len(oldContent) + len(newContent)
+    if total == 0 {
+        return 100
+    }
+    common := 0
+    for _, l := range diffLines(oldContent, newContent) {
+        if l.op == diffmatchpatch.DiffEqual {
+            common += len(l.text) + 1
+        }
+    }
+    return min(common*2*100/total, 100)
+}
+
+// maxSimilarity similarity 的上限：相同行的字节数不超过较短的一侧（末行缺少换行符时多计 1 字节），
+// 据此在逐行对比前排除大小悬殊的配对
+func maxSimilarity(oldSize, newSize int) int {
+    total := oldSize + newSize
+    if total == 0 {
+        return 100
+    }
+    return min((min(oldSize, newSize)+1)*2*100/total, 100)
+}
+
+// detectRenames 在删除与新增的文件之间按相似度配对，相似度不低于 threshold 时视为重命名
+//
+// 与 git 一样先取相似度最高的配对，每个文件只参与一次配对
+func detectRenames(deleted, added map[string]string, threshold int) []renamePair {
+    if threshold <= 0 || len(deleted) == 0 || len(added) == 0 {
+        return nil
+    }
+    // 内容完全相同的文件（如整个目录移动）直接配对，不参与逐行对比
+    var pairs []renamePair
+    usedOld := map[string]bool{}
+    usedNew := map[string]bool{}
+    byContent := map[string][]string{}
+    for newPath, newContent := range added {
+        byContent[newContent] = append(byContent[newContent], newPath)
+    }
+    for _, paths := range byContent {
+        sort.Strings(paths)
+    }
+    oldPaths := make([]string, 0, len(deleted))
+    for oldPath := range deleted {
+        oldPaths = append(oldPaths, oldPath)
+    }
+    sort.Strings(oldPaths)
+    for _, oldPath := range oldPaths {
+        paths := byContent[deleted[oldPath]]
+        if len(paths) == 0 {
+            continue
+        }
+        byContent[deleted[oldPath]] = paths[1:]
+        usedOld[oldPath] = true
+        usedNew[paths[0]] = true
+        pairs = append(pairs, renamePair{oldPath: oldPath, newPath: paths[0], score: 100})
+    }
+
+    var candidates []renamePair
+    for oldPath, oldContent := range deleted {
+        if usedOld[oldPath] {
+            continue
+        }
+        for newPath, newContent := range added {
+            if usedNew[newPath] || maxSimilarity(len(oldContent), len(newContent)) < threshold {
+                continue
+            }
+            if score := similarity(oldContent, newContent); score >= threshold {
+                candidates = append(candidates, renamePair{oldPath: oldPath, newPath: newPath, score: score})
+            }
+        }
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        if candidates[i].score != candidates[j].score {
+            return candidates[i].score > candidates[j].score
+        }
+        return candidates[i].newPath < candidates[j].newPath
+    })
+
+    for _, c := range candidates {
+        if usedOld[c.oldPath] || usedNew[c.newPath] {
+            continue
+        }
+        usedOld[c.oldPath] = true
+        usedNew[c.newPath] = true
+        pairs = append(pairs, c)
+    }
+    return pairs
+}
@@ -1,52 +1,55 @@
 package reviewer
 
 import (
+    "bytes"
     "fmt"
-    "os"
     "path/filepath"
     "strings"
-    "time"
-
-    "github.com/cloudwego/eino/schema"
 )
 
-func (e *Engine) writeReviewToFile(path string, result any, language string) error {
-    workDir, err := e.getWorkPath()
-    if err != nil {
-        return fmt.Errorf("failed to get work path: %v", err)
+// writeMarkdownReport 一次性写入本次运行的报告：运行信息、变更集总览在前，逐文件结果在后
+func (e *Engine) writeMarkdownReport(summary *changesetSummary, reviews []*fileReview) error {
+    var sb strings.Builder
+    sb.WriteString(e.formatRunHeader())
+    if summary != nil {
+        sb.WriteString(e.formatSummary(summary))
+    }
+    for _, review := range reviews {
+        sb.WriteString(e.formatReviewResult(review, getFileLanguage(review.FilePath)))
     }
+    return e.writeReport([]byte(sb.String()), func(old []byte) ([]byte, error) {
+        // 上一次运行的报告在前，本次运行的报告头作为分隔
+        return append(append(bytes.TrimRight(old, "\n"), "\n\n"...), sb.String()...), nil
+    })
+}
 
-    output := e.cfg.OutputFile
-    if output == "" {
-        output = "code-review.md"
+// 格式化审查结果
+func (e *Engine) formatReviewResult(review *fileReview, language string) string {
+    // 报告在全部文件审查完成后才写入，记录的是各文件审查完成的时间
+    reviewedAt := review.ReviewedAt
+    if reviewedAt.IsZero() {
+        reviewedAt = e.started
     }
-    outputFile := filepath.Join(workDir, output)
-    file, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-    if err != nil {
-        return fmt.Errorf("failed to open output file: %v", err)
+    timestamp := reviewedAt.Format("2006-01-02 15:04:05")
+    filePath := review.FilePath
+    if review.OldPath != "" {
+        filePath = fmt.Sprintf("%s ← %s", review.FilePath, review.OldPath)
     }
-    defer file.Close()
 
-    // 格式化内容
-    content := e.formatReviewResult(path, result, language)
-
-    // 写入内容
-    if _, err := file.WriteString(content); err != nil {
-        return fmt.Errorf("failed to write to file: %v", err)
+    // 启用回退链时，结果可能来自主模型之外的模型
+    var modelLine string
+    if review.Model != "" {
+        label := "模型"
+        if e.cfg.Language == "en" {
+            label = "Model"
+        }
+        modelLine = fmt.Sprintf("  \n**%s**: %s", label, review.Model)
     }
-    return nil
-}
-
-// 格式化审查结果
-func (e *Engine) formatReviewResult(filePath string, result any, language string) string {
-    timestamp := time.Now().Format("2006-01-02 15:04:05")
 
-    var content string
-    // 如果结果是 *schema.Message 类型，提取内容
-    if msg, ok := result.(*schema.Message); ok {
-        content = msg.Content
-    } else {
-        content = fmt.Sprintf("%v", result)
+    content := e.formatFindings(review)
+    var reasoning string
+    if e.cfg.ThinkingChain {
+        reasoning = review.Reasoning
     }
 
     // 根据语言设置选择模板
@@ -56,15 +59,15 @@ func (e *Engine) formatReviewResult(filePath string, result any, language string
 
 **File Path**: %s  
 **File Type**: %s  
-**Review Time**: %s
+**Review Time**: %s%s
 
-### Review Result
+%s### Review Result
 
 %s
 
 ---
 
-`, filePath, language, timestamp, content)
+`, filePath, language, timestamp, modelLine, formatThinking(reasoning, "Thinking Chain"), content)
     } else {
         // 默认中文模板
         return fmt.Sprintf(`
@@ -72,16 +75,112 @@ func (e *Engine) formatReviewResult(filePath string, result any, language string
 
 **文件路径**: %s  
 **文件类型**: %s  
-**审查时间**: %s
+**审查时间**: %s%s
 
-### 审查结果
+%s### 审查结果
 
 %s
 
 ---
 
-`, filePath, language, timestamp, content)
+`, filePath, language, timestamp, modelLine, formatThinking(reasoning, "思考过程"), content)
+    }
+}
+
+// formatFindings 将结构化结果渲染为 Markdown 列表；非结构化结果原样输出
+func (e *Engine) formatFindings(review *fileReview) string {
+    en := e.cfg.Language == "en"
+    if review.Skipped != "" {
+        if en {
+            return "⊘ Skipped: " + review.Skipped
+        }
+        return "⊘ 已跳过：" + review.Skipped
+    }
+    if review.Failed != "" {
+        return "✖ " + review.Failed
+    }
+    if !review.structured() {
+        return review.Raw
+    }
+
+    var sb strings.Builder
+    if review.Summary != "" {
+        sb.WriteString(review.Summary)
+        sb.WriteString("\n\n")
+    }
+    if len(review.Findings) == 0 {
+        if en {
+            sb.WriteString("No issues found.\n")
+        } else {
+            sb.WriteString("未发现问题。\n")
+        }
+        return sb.String()
+    }
+
+    for _, f := range review.Findings {
+        fmt.Fprintf(&sb, "- %s **%s** · `%s`", severityIcon(f.Severity), f.Severity, findingLocation(f))
+        if f.Category != "" {
+            fmt.Fprintf(&sb, " · %s", f.Category)
+        }
+        sb.WriteString("\n\n")
+        sb.WriteString(indent(f.Message, "  "))
+        sb.WriteString("\n\n")
+        if f.Suggestion != "" {
+            if en {
+                sb.WriteString("  **Suggestion**:\n\n")
+            } else {
+                sb.WriteString("  **建议**：\n\n")
+            }
+            sb.WriteString(indent(f.Suggestion, "  "))
+            sb.WriteString("\n\n")
+        }
+    }
+    return strings.TrimRight(sb.String(), "\n")
+}
+
+// findingLocation 形如 a.go:12-15，无行号时只有文件路径
+func findingLocation(f Finding) string {
+    switch {
+    case f.StartLine == 0:
+        return f.File
+    case f.EndLine > f.StartLine:
+        return fmt.Sprintf("%s:%d-%d", f.File, f.StartLine, f.EndLine)
+    default:
+        return fmt.Sprintf("%s:%d", f.File, f.StartLine)
+    }
+}
+
+func severityIcon(s Severity) string {
+    switch s {
+    case SeverityCritical:
+        return "🔴"
+    case SeverityMajor:
+        return "🟠"
+    case SeverityMinor:
+        return "🟡"
+    default:
+        return "🔵"
+    }
+}
+
+// indent 为多行文本的每个非空行添加前缀，使其位于列表项内
+func indent(s, prefix string) string {
+    lines := strings.Split(strings.TrimSpace(s), "\n")
+    for i, l := range lines {
+        if l != "" {
+            lines[i] = prefix + l
+        }
+    }
+    return strings.Join(lines, "\n")
+}
+
+// formatThinking 将思考过程渲染为可折叠区块，无内容时返回空串
+func formatThinking(reasoning, title string) string {
+    reasoning = strings.TrimSpace(reasoning)
+    if reasoning == "" {
+        return ""
     }
+    return fmt.Sprintf("<details>\n<summary>%s</summary>\n\n%s\n\n</details>\n\n", title, reasoning)
 }
 
 // 获取文件语言类型的辅助函数
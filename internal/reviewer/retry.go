@@ -0,0 +1,179 @@
+package reviewer
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand/v2"
+    "net"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/anthropics/anthropic-sdk-go"
+    "github.com/cloudwego/eino/compose"
+    "github.com/cloudwego/eino/schema"
+    "github.com/fatih/color"
+)
+
+const (
+    // 可重试错误的默认重试次数
+    DefaultRetries = 3
+    // 单次模型请求的默认超时
+    DefaultRequestTimeout = 3 * time.Minute
+
+    retryBaseDelay = time.Second
+    retryMaxDelay  = 30 * time.Second
+    // 服务端建议的等待时间上限，避免异常的 Retry-After 使审查长时间挂起
+    retryAfterMax = 2 * time.Minute
+)
+
+// OpenAI 兼容接口在限流错误信息中给出等待时间，形如 "Please try again in 1.5s"
+var retryAfterPattern = regexp.MustCompile(`(?i)(?:try again in|retry after)\s+(\d+(?:\.\d+)?(?:ms|s|m))\b`)
+
+var errRequestTimeout = errors.New("request timed out")
+
+// modelCallError 模型调用最终失败时的错误，记录实际发出的请求次数
+type modelCallError struct {
+    attempts int
+    err      error
+}
+
+func (e *modelCallError) Error() string {
+    return e.err.Error()
+}
+
+func (e *modelCallError) Unwrap() error {
+    return e.err
+}
+
+// attemptsOf 失败前发出的模型请求次数，未调用模型时为 0
+func attemptsOf(err error) int {
+    var mce *modelCallError
+    if errors.As(err, &mce) {
+        return mce.attempts
+    }
+    return 0
+}
+
+// callModel 调用模型，每次请求单独计时；超时、限流、5xx 与网络错误按带抖动的指数退避重试
+func (e *Engine) callModel(r compose.Runnable[map[string]any, *schema.Message], input map[string]any, name string) (*schema.Message, error) {
+    retries := max(e.cfg.Retries, 0)
+    for attempt := 1; ; attempt++ {
+        ctx, cancel := e.ctx, context.CancelFunc(func() {})
+        if e.cfg.RequestTimeout > 0 {
+            ctx, cancel = context.WithTimeout(e.ctx, e.cfg.RequestTimeout)
+        }
+        var ret *schema.Message
+        var err error
+        if e.cfg.ThinkingChain {
+            // 流式调用，实时输出思考过程
+            ret, err = e.streamWithThinking(ctx, r, input, name)
+        } else {
+            ret, err = r.Invoke(ctx, input)
+        }
+        timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && e.ctx.Err() == nil
+        cancel()
+        if err == nil {
+            return ret, nil
+        }
+        if timedOut {
+            err = fmt.Errorf("%w after %v: %v", errRequestTimeout, e.cfg.RequestTimeout, err)
+        }
+        if attempt > retries || !e.retryable(err) {
+            return nil, &modelCallError{attempts: attempt, err: err}
+        }
+
+        delay := retryAfterOf(err)
+        if delay <= 0 {
+            delay = backoffDelay(attempt)
+        }
+        color.Yellow("↻ retry %d/%d in %v: %s, err=%v\n", attempt, retries, delay.Round(100*time.Millisecond), name, err)
+        select {
+        case <-time.After(delay):
+        case <-e.ctx.Done():
+            return nil, &modelCallError{attempts: attempt, err: e.ctx.Err()}
+        }
+    }
+}
+
+// retryable 判断错误是否值得重试；自适应并发下的限流交给 workerPool 收缩后重新排队
+func (e *Engine) retryable(err error) bool {
+    if e.ctx.Err() != nil {
+        return false
+    }
+    if errors.Is(err, errRequestTimeout) {
+        return true
+    }
+    if e.cfg.AdaptivePool && isOverloaded(err) {
+        return false
+    }
+    switch code := statusCodeOf(err); {
+    case code == 408 || code == 409 || code == 425 || code == 429 || code >= 500:
+        return true
+    case code != 0:
+        return false
+    }
+
+    var netErr net.Error
+    if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+        return true
+    }
+    // 部分客户端只保留了底层错误的文本
+    msg := err.Error()
+    for _, s := range []string{"connection reset", "connection refused", "broken pipe", "unexpected EOF", "i/o timeout", "TLS handshake timeout"} {
+        if strings.Contains(msg, s) {
+            return true
+        }
+    }
+    return false
+}
+
+// retryAfterOf 服务端建议的等待时间，没有时返回 0
+//
+// Anthropic 客户端在错误中保留了响应，读取 Retry-After 头；其他客户端只能从错误信息中提取
+func retryAfterOf(err error) time.Duration {
+    var d time.Duration
+    var apiErr *anthropic.Error
+    if errors.As(err, &apiErr) && apiErr.Response != nil {
+        if v := apiErr.Response.Header.Get("Retry-After"); v != "" {
+            if secs, perr := strconv.Atoi(v); perr == nil {
+                d = time.Duration(secs) * time.Second
+            } else if t, perr := http.ParseTime(v); perr == nil {
+                d = time.Until(t)
+            }
+        }
+    }
+    if d <= 0 {
+        if m := retryAfterPattern.FindStringSubmatch(err.Error()); m != nil {
+            d, _ = time.ParseDuration(m[1])
+        }
+    }
+    return min(max(d, 0), retryAfterMax)
+}
+
+// backoffDelay 第 attempt 次失败后的等待时间：1s、2s、4s... 封顶 30s，并在 [d/2, d] 内随机抖动
+func backoffDelay(attempt int) time.Duration {
+    d := retryBaseDelay << min(attempt-1, 10)
+    d = min(d, retryMaxDelay)
+    return d/2 + rand.N(d/2+1)
+}
+
+// failureText 报告中记录的失败原因
+func (e *Engine) failureText(err error) string {
+    attempts := attemptsOf(err)
+    if e.cfg.Language == "en" {
+        if attempts > 0 {
+            return fmt.Sprintf("review failed after %d attempt(s): %v", attempts, err)
+        }
+        return fmt.Sprintf("review failed: %v", err)
+    }
+    if attempts > 0 {
+        return fmt.Sprintf("审查失败（共尝试 %d 次）：%v", attempts, err)
+    }
+    return fmt.Sprintf("审查失败：%v", err)
+}
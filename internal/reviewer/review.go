@@ -2,71 +2,142 @@ package reviewer
 
 import (
     "fmt"
-    "path/filepath"
     "time"
 
+    "github.com/cloudwego/eino/components/model"
     "github.com/cloudwego/eino/components/prompt"
     "github.com/cloudwego/eino/compose"
     "github.com/cloudwego/eino/schema"
     "github.com/fatih/color"
 )
 
-// reviewSingleFile 对单个文件变更进行审查并写入报告
-func (e *Engine) reviewSingleFile(d gitDiff) error {
-    if e.chatModel == nil {
-        return fmt.Errorf("chat model is nil")
+// reviewSingleFile 对单个文件变更（或其中一个拆分部分）进行审查
+func (e *Engine) reviewSingleFile(d gitDiff) (*fileReview, error) {
+    // 打印审查开始
+    name := d.FilePath
+    if d.ChangeType == changeBatch {
+        name = batchName(d.Files)
+    } else if d.Chunks > 1 {
+        name = fmt.Sprintf("%s (%d/%d)", d.FilePath, d.Chunk, d.Chunks)
     }
+    color.Cyan("▶ review: %s\n", name)
+    start := time.Now()
 
-    g := compose.NewGraph[map[string]any, *schema.Message]()
-    ext := filepath.Ext(d.FilePath)
+    // 内置 prompt 或 --prompt-file 模板；渲染结果作为变量传入，避免其中的花括号被 FString 解析
+    systemPrompt, userQuery, err := e.buildPrompt(d)
+    if err != nil {
+        return nil, err
+    }
+    // prompt、模型、语言与 --thinking-chain 都未变化时直接复用上次的结果
+    key := e.cacheKey(systemPrompt, userQuery)
+    if review, ok := e.cache.get(key, d); ok {
+        color.Green("♻ cached: %s\n", name)
+        return review, nil
+    }
 
-    // 打印审查开始
-    color.Cyan("▶ review: %s\n", d.FilePath)
-    start := time.Now()
+    review, err := e.invokeReview(systemPrompt, userQuery, d)
+    if err != nil {
+        return nil, err
+    }
+    review.OldPath = d.OldPath
+    e.cache.put(key, review)
+
+    duration := time.Since(start)
+    color.Green("✔ reviewed: %s in %v\n", name, duration)
+    return review, nil
+}
 
-    // 根据语言设置选择提示词
-    var systemTpl string
-    if e.cfg.Language == "en" {
-        systemTpl = fmt.Sprintf("You are a %s development expert. You will provide code review conclusions for the code changes provided by the user. Please output the issues in the original code, your review suggestions, and modification proposals in your conclusion. Please keep the total output within 200 words", ext)
-    } else {
-        // 默认中文
-        systemTpl = fmt.Sprintf("你是一位  %s 研发专家，现在你将对用户给出的代码变更内容给出对应的code reviewer 结论。我需要你在结论中输出原有代码相关问题，你的评审建议，与修改方案.请将整体输出控制在200字内", ext)
+// recordFailed 重试后仍失败的文件在报告中记录尝试次数与最终错误
+func (e *Engine) recordFailed(d gitDiff, err error) {
+    color.Red("✖ review failed: %s, err=%v\n", d.FilePath, err)
+    if err := e.saveReview(&fileReview{FilePath: d.FilePath, OldPath: d.OldPath, Failed: e.failureText(err)}); err != nil {
+        color.Red("✖ record failure failed: %s, err=%v\n", d.FilePath, err)
     }
+}
+
+// recordSkipped 二进制或超过大小上限的文件不调用模型，只在报告中记录跳过原因
+func (e *Engine) recordSkipped(d gitDiff) error {
+    return e.saveReview(&fileReview{FilePath: d.FilePath, OldPath: d.OldPath, Skipped: d.SkipReason})
+}
 
+// saveReview 暂存单个文件的审查结果并输出进度，全部完成后排序写入报告
+func (e *Engine) saveReview(review *fileReview) error {
+    e.mutex.Lock()
+    defer e.mutex.Unlock()
+
+    if review.ReviewedAt.IsZero() {
+        review.ReviewedAt = time.Now()
+    }
+    e.reviews = append(e.reviews, review)
+    e.done++
+    if e.total > 0 {
+        color.Cyan("● progress: %d/%d files\n", e.done, e.total)
+    }
+    return nil
+}
+
+// compileGraph 构建 prompt -> model 的调用图，输入为 system_prompt / message_histories / user_query
+func (e *Engine) compileGraph(cm model.BaseChatModel) (compose.Runnable[map[string]any, *schema.Message], error) {
+    g := compose.NewGraph[map[string]any, *schema.Message]()
     chatTpl := prompt.FromMessages(schema.FString,
-        schema.SystemMessage(systemTpl),
+        schema.SystemMessage("{system_prompt}"),
         schema.MessagesPlaceholder("message_histories", true),
         schema.UserMessage("{user_query}"),
     )
     _ = g.AddChatTemplateNode(nodeOfPrompt, chatTpl)
-    _ = g.AddChatModelNode(nodeOfModel, e.chatModel)
+    _ = g.AddChatModelNode(nodeOfModel, cm)
     _ = g.AddEdge(compose.START, nodeOfPrompt)
     _ = g.AddEdge(nodeOfPrompt, nodeOfModel)
     _ = g.AddEdge(nodeOfModel, compose.END)
     r, err := g.Compile(e.ctx, compose.WithMaxRunSteps(10))
     if err != nil {
         // 不再 panic，返回错误
-        return fmt.Errorf("compile graph failed: %w", err)
+        return nil, fmt.Errorf("compile graph failed: %w", err)
     }
+    return r, nil
+}
 
-    ret, err := r.Invoke(e.ctx, map[string]any{
-        "message_histories": []*schema.Message{},
-        "user_query":        d.Content,
-    })
-    if err != nil {
-        return fmt.Errorf("invoke failed: %w", err)
-    }
+// 模型输出无法解析为 JSON 时，追加纠正消息重新请求的次数上限
+const maxFormatRetries = 2
 
-    e.mutex.Lock()
-    defer e.mutex.Unlock()
+// invokeReview 调用模型并解析结构化结果；输出不合法时带上历史对话要求模型重新输出，仍失败则退化为原始文本
+func (e *Engine) invokeReview(systemPrompt, userQuery string, d gitDiff) (*fileReview, error) {
+    filePath := d.FilePath
+    // 批量审查的每条问题必须自带 file
+    findingsFile := filePath
+    if d.ChangeType == changeBatch {
+        findingsFile = ""
+    }
+    histories := []*schema.Message{}
+    query := userQuery
+    for attempt := 0; ; attempt++ {
+        input := map[string]any{
+            "system_prompt":     systemPrompt,
+            "message_histories": histories,
+            "user_query":        query,
+        }
+        ret, modelName, err := e.invoke(input, filePath)
+        if err != nil {
+            return nil, fmt.Errorf("invoke failed: %w", err)
+        }
 
-    lang := getFileLanguage(d.FilePath)
-    if err := e.writeReviewToFile(d.FilePath, ret, lang); err != nil {
-        return fmt.Errorf("write review failed: %w", err)
+        review, perr := parseFindings(ret.Content, findingsFile)
+        if perr == nil {
+            if d.ChangeType != changeBatch {
+                resolveFindingPaths(review.Findings, d, userQuery)
+            }
+            review.Reasoning = reasoningOf(ret)
+            review.Model = modelName
+            return review, nil
+        }
+        if attempt >= maxFormatRetries {
+            color.Yellow("⚠ unstructured output, fallback to text: %s, err=%v\n", filePath, perr)
+            return &fileReview{FilePath: filePath, Raw: ret.Content, Reasoning: reasoningOf(ret), Model: modelName}, nil
+        }
+        color.Yellow("↻ malformed output, retry: %s, err=%v\n", filePath, perr)
+        histories = append(histories, schema.UserMessage(query), ret)
+        query = retryFormatMessage(e.cfg.Language, perr)
     }
-    duration := time.Since(start)
-    color.Green("✔ reviewed: %s in %v\n", d.FilePath, duration)
-    return nil
 }
 
 const (
@@ -0,0 +1,248 @@
+package reviewer
+
+import (
+    "encoding/json"
+    "fmt"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// 报告输出格式
+const (
+    FormatMarkdown = "markdown"
+    FormatSARIF    = "sarif"
+)
+
+const (
+    sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+    sarifVersion = "2.1.0"
+    // 非结构化结果（模型未按 JSON 输出）使用的规则
+    sarifTextRule = "stellarspec/review"
+)
+
+type sarifLog struct {
+    Schema  string     `json:"$schema"`
+    Version string     `json:"version"`
+    Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+    Tool        sarifTool         `json:"tool"`
+    Invocations []sarifInvocation `json:"invocations,omitempty"`
+    Results     []sarifResult     `json:"results"`
+    // 运行信息与变更集总览（--summary）放在 run 的属性包中
+    Properties map[string]any `json:"properties,omitempty"`
+}
+
+// sarifInvocation 跳过的文件以工具执行通知的形式记录，不产生 result
+type sarifInvocation struct {
+    ExecutionSuccessful        bool                `json:"executionSuccessful"`
+    ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications"`
+}
+
+type sarifNotification struct {
+    Level     string          `json:"level"`
+    Message   sarifMessage    `json:"message"`
+    Locations []sarifLocation `json:"locations"`
+}
+
+type sarifTool struct {
+    Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+    Name           string      `json:"name"`
+    InformationURI string      `json:"informationUri"`
+    Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+    ID               string       `json:"id"`
+    Name             string       `json:"name"`
+    ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+    RuleID     string          `json:"ruleId"`
+    Level      string          `json:"level"`
+    Message    sarifMessage    `json:"message"`
+    Locations  []sarifLocation `json:"locations"`
+    Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+    Text string `json:"text"`
+}
+
+type sarifLocation struct {
+    PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+    ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+    Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+    URI       string `json:"uri"`
+    URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+    StartLine int `json:"startLine"`
+    EndLine   int `json:"endLine,omitempty"`
+}
+
+// writeSARIF 将本次运行的全部审查结果写为一个 SARIF 2.1.0 run；append 模式下追加到已有日志的 runs 中
+func (e *Engine) writeSARIF(reviews []*fileReview, summary *changesetSummary) error {
+    log := buildSARIF(reviews)
+    props := map[string]any{"run": e.runInfo()}
+    if summary != nil {
+        props["changesetSummary"] = summary
+    }
+    log.Runs[0].Properties = props
+    data, err := json.MarshalIndent(log, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to marshal sarif: %v", err)
+    }
+    return e.writeReport(data, func(old []byte) ([]byte, error) {
+        // 已有日志可能来自其他工具，只追加 run，其余字段原样保留
+        var existing map[string]any
+        if err := json.Unmarshal(old, &existing); err != nil {
+            return nil, fmt.Errorf("existing output is not a sarif log: %v", err)
+        }
+        runs, _ := existing["runs"].([]any)
+        existing["runs"] = append(runs, log.Runs[0])
+        return json.MarshalIndent(existing, "", "  ")
+    })
+}
+
+// buildSARIF 每条 finding 对应一个 result，按类别生成规则
+func buildSARIF(reviews []*fileReview) *sarifLog {
+    rules := map[string]sarifRule{}
+    results := []sarifResult{}
+    // 跳过与重试后仍失败的文件记为工具执行通知
+    var notifications []sarifNotification
+    failed := false
+    for _, review := range reviews {
+        if review.Skipped != "" {
+            notifications = append(notifications, sarifNotification{
+                Level:     "note",
+                Message:   sarifMessage{Text: "skipped: " + review.Skipped},
+                Locations: []sarifLocation{sarifLocationOf(review.FilePath, 0, 0)},
+            })
+            continue
+        }
+        if review.Failed != "" {
+            failed = true
+            notifications = append(notifications, sarifNotification{
+                Level:     "error",
+                Message:   sarifMessage{Text: review.Failed},
+                Locations: []sarifLocation{sarifLocationOf(review.FilePath, 0, 0)},
+            })
+            continue
+        }
+        // 部分拆分失败时其余部分的结果照常输出
+        if review.FailedParts > 0 {
+            failed = true
+            notifications = append(notifications, sarifNotification{
+                Level:     "error",
+                Message:   sarifMessage{Text: fmt.Sprintf("%d part(s) failed to review", review.FailedParts)},
+                Locations: []sarifLocation{sarifLocationOf(review.FilePath, 0, 0)},
+            })
+        }
+        if !review.structured() {
+            rules[sarifTextRule] = sarifRule{ID: sarifTextRule, Name: "review", ShortDescription: sarifMessage{Text: "Free-form review"}}
+            results = append(results, sarifResult{
+                RuleID:     sarifTextRule,
+                Level:      "note",
+                Message:    sarifMessage{Text: review.Raw},
+                Locations:  []sarifLocation{sarifLocationOf(review.FilePath, 0, 0)},
+                Properties: resultProperties(review, nil),
+            })
+            continue
+        }
+        for _, f := range review.Findings {
+            category := f.Category
+            if category == "" {
+                category = "general"
+            }
+            ruleID := "stellarspec/" + category
+            rules[ruleID] = sarifRule{ID: ruleID, Name: category, ShortDescription: sarifMessage{Text: category}}
+
+            text := f.Message
+            if f.Suggestion != "" {
+                text += "\n\nSuggestion: " + f.Suggestion
+            }
+            results = append(results, sarifResult{
+                RuleID:     ruleID,
+                Level:      sarifLevel(f.Severity),
+                Message:    sarifMessage{Text: text},
+                Locations:  []sarifLocation{sarifLocationOf(f.File, f.StartLine, f.EndLine)},
+                Properties: resultProperties(review, map[string]any{"severity": string(f.Severity)}),
+            })
+        }
+    }
+
+    ruleList := make([]sarifRule, 0, len(rules))
+    for _, r := range rules {
+        ruleList = append(ruleList, r)
+    }
+    sort.Slice(ruleList, func(i, j int) bool { return ruleList[i].ID < ruleList[j].ID })
+
+    run := sarifRun{
+        Tool: sarifTool{Driver: sarifDriver{
+            Name:           "stellarspec",
+            InformationURI: "https://github.com/zzy2210/stellarspec",
+            Rules:          ruleList,
+        }},
+        Results: results,
+    }
+    if len(notifications) > 0 {
+        run.Invocations = []sarifInvocation{{ExecutionSuccessful: !failed, ToolExecutionNotifications: notifications}}
+    }
+    return &sarifLog{
+        Schema:  sarifSchema,
+        Version: sarifVersion,
+        Runs:    []sarifRun{run},
+    }
+}
+
+// sarifLocationOf startLine 为 0 时不输出 region（SARIF 行号从 1 开始）
+func sarifLocationOf(file string, startLine, endLine int) sarifLocation {
+    loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+        ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(strings.TrimPrefix(file, "./")), URIBaseID: "%SRCROOT%"},
+    }}
+    if startLine > 0 {
+        loc.PhysicalLocation.Region = &sarifRegion{StartLine: startLine}
+        if endLine > startLine {
+            loc.PhysicalLocation.Region.EndLine = endLine
+        }
+    }
+    return loc
+}
+
+// sarifLevel 严重程度映射到 SARIF level
+func sarifLevel(s Severity) string {
+    switch s {
+    case SeverityCritical, SeverityMajor:
+        return "error"
+    case SeverityMinor:
+        return "warning"
+    default:
+        return "note"
+    }
+}
+
+// resultProperties 在 result 的属性中记录产出结果的模型
+func resultProperties(review *fileReview, props map[string]any) map[string]any {
+    if review.Model == "" {
+        return props
+    }
+    if props == nil {
+        props = map[string]any{}
+    }
+    props["model"] = review.Model
+    return props
+}
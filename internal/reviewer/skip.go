@@ -0,0 +1,77 @@
+package reviewer
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/fatih/color"
+)
+
+// unreviewable 检查文件是否为二进制或超过 --max-file-size，返回跳过原因（按报告语言），可审查时返回空串
+//
+// contents 为变更前后的内容，任意一侧为二进制即跳过；size 为发送给模型的文件大小
+func (e *Engine) unreviewable(file string, size int, contents ...string) string {
+    for _, c := range contents {
+        if isBinary([]byte(c[:min(len(c), 8000)])) {
+            color.Yellow("⊘ skip binary: %s\n", file)
+            if e.cfg.Language == "en" {
+                return "binary file"
+            }
+            return "二进制文件"
+        }
+    }
+    return e.oversized(file, int64(size))
+}
+
+// oversized 超过 --max-file-size 时返回跳过原因，上限为 0 表示不限制
+func (e *Engine) oversized(file string, size int64) string {
+    limit := int64(e.cfg.MaxFileSize) * 1024
+    if limit <= 0 || size <= limit {
+        return ""
+    }
+    color.Yellow("⊘ skip oversized: %s (%s)\n", file, formatSize(size))
+    if e.cfg.Language == "en" {
+        return fmt.Sprintf("file too large (%s, limit %s)", formatSize(size), formatSize(limit))
+    }
+    return fmt.Sprintf("文件过大（%s，上限 %s）", formatSize(size), formatSize(limit))
+}
+
+// readWorktreeFile 读取工作区文件；超过大小上限时不读取内容，直接返回跳过原因
+func (e *Engine) readWorktreeFile(file string) (content, reason string, err error) {
+    full := filepath.Join(e.repoRoot, file)
+    info, err := os.Stat(full)
+    if err != nil {
+        return "", "", err
+    }
+    if reason := e.oversized(file, info.Size()); reason != "" {
+        return "", reason, nil
+    }
+    content, err = e.getFileContent(full)
+    if err != nil {
+        return "", "", err
+    }
+    return content, e.unreviewable(file, len(content), content), nil
+}
+
+// skippedDiff 不发送给模型的文件，报告中只记录跳过原因
+func skippedDiff(file, oldPath, changeType, reason string) gitDiff {
+    return gitDiff{FilePath: file, OldPath: oldPath, ChangeType: changeType, SkipReason: reason}
+}
+
+// formatSize 以 B / KB / MB 显示文件大小
+func formatSize(size int64) string {
+    unit := func(v float64, suffix string) string {
+        return strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0") + " " + suffix
+    }
+    switch {
+    case size >= 1<<20:
+        return unit(float64(size)/(1<<20), "MB")
+    case size >= 1<<10:
+        return unit(float64(size)/(1<<10), "KB")
+    default:
+        return fmt.Sprintf("%d B", size)
+    }
+}
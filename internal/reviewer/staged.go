@@ -0,0 +1,119 @@
+package reviewer
+
+import (
+    "errors"
+    "fmt"
+
+    "github.com/fatih/color"
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing"
+    "github.com/go-git/go-git/v5/plumbing/filemode"
+    "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// stagedDiff 收集暂存区相对 HEAD 的变更（--staged），即 git commit 将要提交的内容，供 pre-commit hook 使用
+//
+// 文件内容取自暂存区的 blob，工作区中未暂存的修改不参与审查；仓库还没有提交时全部视为新增
+func (e *Engine) stagedDiff(repo *git.Repository) ([]gitDiff, error) {
+    idx, err := repo.Storer.Index()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read index: %v", err)
+    }
+
+    var headTree *object.Tree
+    head := map[string]plumbing.Hash{}
+    ref, err := repo.Head()
+    switch {
+    case errors.Is(err, plumbing.ErrReferenceNotFound):
+        e.target = "staged (initial commit)"
+    case err != nil:
+        return nil, fmt.Errorf("failed to get HEAD: %v", err)
+    default:
+        commit, err := repo.CommitObject(ref.Hash())
+        if err != nil {
+            return nil, fmt.Errorf("failed to get commit: %v", err)
+        }
+        if headTree, err = commit.Tree(); err != nil {
+            return nil, fmt.Errorf("failed to get HEAD tree: %v", err)
+        }
+        err = headTree.Files().ForEach(func(f *object.File) error {
+            head[f.Name] = f.Hash
+            return nil
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to list HEAD tree: %v", err)
+        }
+        e.target = fmt.Sprintf("staged vs %s (%s)", ref.Name().Short(), ref.Hash().String()[:7])
+    }
+    color.Cyan("◆ %s\n", e.target)
+
+    diffs := []gitDiff{}
+    added := map[string]string{}
+    deleted := map[string]string{}
+    staged := map[string]bool{}
+    for _, entry := range idx.Entries {
+        // 冲突未解决的文件（stage 1-3）无法提交，子模块没有可审查的内容；
+        // 注意 go-git 的 index.Merged 常量为 1，而已合并的条目实际解码为 0
+        if entry.Stage != 0 || entry.Mode == filemode.Submodule {
+            continue
+        }
+        file := entry.Name
+        staged[file] = true
+        oldHash, inHead := head[file]
+        if (inHead && oldHash == entry.Hash) || e.skipFile(file) {
+            continue
+        }
+        newContent, err := blobContent(repo, entry.Hash)
+        if err != nil {
+            color.Red("failed to get staged content: path=%s, err=%v\n", file, err)
+            continue
+        }
+        if !inHead {
+            if reason := e.unreviewable(file, len(newContent), newContent); reason != "" {
+                diffs = append(diffs, skippedDiff(file, "", changeAdded, reason))
+                continue
+            }
+            added[file] = newContent
+            continue
+        }
+
+        oldContent, err := blobContent(repo, oldHash)
+        if err != nil {
+            color.Red("failed to get HEAD content: path=%s, err=%v\n", file, err)
+            continue
+        }
+        if reason := e.unreviewable(file, len(newContent), oldContent, newContent); reason != "" {
+            diffs = append(diffs, skippedDiff(file, "", changeModified, reason))
+            continue
+        }
+        if e.skipGenerated(file, newContent) {
+            continue
+        }
+        diffs = append(diffs, gitDiff{FilePath: file, Content: e.generateProfessionalDiff(file, oldContent, newContent), ChangeType: changeModified, NewContent: newContent, OldContent: oldContent})
+        color.Yellow("Δ mod: %s\n", file)
+    }
+
+    for file, hash := range head {
+        if staged[file] || e.skipFile(file) {
+            continue
+        }
+        oldContent, err := blobContent(repo, hash)
+        if err != nil {
+            color.Red("failed to get deleted file content: path=%s, err=%v\n", file, err)
+            continue
+        }
+        if reason := e.unreviewable(file, 0, oldContent); reason != "" {
+            diffs = append(diffs, skippedDiff(file, "", changeDeleted, reason))
+            continue
+        }
+        deleted[file] = oldContent
+    }
+
+    // 残留引用在工作区中查找，与暂存区通常一致
+    worktree, err := repo.Worktree()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get work tree: %v", err)
+    }
+    e.walker = e.worktreeWalker(worktree)
+    return e.pairChanges(diffs, added, deleted), nil
+}
@@ -0,0 +1,233 @@
+package reviewer
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/cloudwego/eino/schema"
+    "github.com/fatih/color"
+)
+
+const (
+    // 总览中每个文件附带的 diff 行数上限
+    maxSummaryDiffLines = 40
+    // 总览中每个文件列出的问题数上限
+    maxSummaryFindings = 10
+)
+
+// changesetSummary 整个变更集的总览
+type changesetSummary struct {
+    Risk            string   `json:"risk"`
+    Overview        string   `json:"overview"`
+    CrossFileIssues []string `json:"cross_file_issues"`
+    MissingTests    []string `json:"missing_tests"`
+    CommitTitle     string   `json:"commit_title"`
+    CommitBody      string   `json:"commit_body"`
+    // 模型输出无法解析时保留原始文本
+    Raw string `json:"raw,omitempty"`
+}
+
+// summarizeChangeset 在逐文件审查完成后，将变更文件列表、截断的 diff 与逐文件问题发送给模型，生成变更集总览
+func (e *Engine) summarizeChangeset(diffs []gitDiff, reviews []*fileReview) (*changesetSummary, error) {
+    color.Cyan("▶ summary: %d files\n", len(diffs))
+    start := time.Now()
+
+    histories := []*schema.Message{}
+    query := e.summaryInput(diffs, reviews)
+    for attempt := 0; ; attempt++ {
+        input := map[string]any{
+            "system_prompt":     summarySystemPrompt(e.cfg.Language),
+            "message_histories": histories,
+            "user_query":        query,
+        }
+        ret, _, err := e.invoke(input, "summary")
+        if err != nil {
+            return nil, fmt.Errorf("invoke failed: %w", err)
+        }
+
+        summary, perr := parseSummary(ret.Content)
+        if perr == nil {
+            color.Green("✔ summary in %v\n", time.Since(start))
+            return summary, nil
+        }
+        if attempt >= maxFormatRetries {
+            color.Yellow("⚠ unstructured summary, fallback to text: err=%v\n", perr)
+            return &changesetSummary{Raw: ret.Content}, nil
+        }
+        color.Yellow("↻ malformed summary, retry: err=%v\n", perr)
+        histories = append(histories, schema.UserMessage(query), ret)
+        query = retryFormatMessage(e.cfg.Language, perr)
+    }
+}
+
+// summaryInput 变更文件列表（含变更类型）、每个文件截断后的 diff 以及逐文件审查结果
+//
+// diff 部分受 --chunk-tokens 预算限制，超出后的文件只列出审查结果
+func (e *Engine) summaryInput(diffs []gitDiff, reviews []*fileReview) string {
+    budget := e.cfg.ChunkTokens
+    if budget <= 0 {
+        budget = DefaultChunkTokens
+    }
+    byFile := map[string]*fileReview{}
+    for _, r := range reviews {
+        byFile[r.FilePath] = r
+    }
+
+    var sb strings.Builder
+    sb.WriteString("Changed files:\n")
+    for _, d := range diffs {
+        fmt.Fprintf(&sb, "- %s (%s", d.FilePath, d.ChangeType)
+        if d.OldPath != "" {
+            fmt.Fprintf(&sb, " from %s", d.OldPath)
+        }
+        if d.SkipReason != "" {
+            fmt.Fprintf(&sb, ", skipped: %s", d.SkipReason)
+        }
+        sb.WriteString(")\n")
+    }
+
+    tokens := 0
+    for _, d := range diffs {
+        fmt.Fprintf(&sb, "\n=== %s ===\n", d.FilePath)
+        if d.SkipReason == "" && tokens < budget {
+            diff := truncateLines(d.Content, maxSummaryDiffLines)
+            tokens += estimateTokens(diff)
+            sb.WriteString("Diff (truncated):\n")
+            sb.WriteString(diff)
+        }
+        if r := byFile[d.FilePath]; r != nil && r.Skipped == "" && r.Failed == "" {
+            sb.WriteString(summaryOfReview(r))
+        }
+    }
+    return sb.String()
+}
+
+// summaryOfReview 单个文件的审查结果摘要
+func summaryOfReview(r *fileReview) string {
+    var sb strings.Builder
+    if !r.structured() {
+        sb.WriteString("Review:\n")
+        sb.WriteString(truncateLines(r.Raw, maxSummaryDiffLines))
+        return sb.String()
+    }
+    if r.Summary != "" {
+        fmt.Fprintf(&sb, "Review summary: %s\n", r.Summary)
+    }
+    if len(r.Findings) == 0 {
+        sb.WriteString("Findings: none\n")
+        return sb.String()
+    }
+    sb.WriteString("Findings:\n")
+    for i, f := range r.Findings {
+        if i == maxSummaryFindings {
+            fmt.Fprintf(&sb, "- ... (+%d)\n", len(r.Findings)-i)
+            break
+        }
+        fmt.Fprintf(&sb, "- [%s] %s: %s\n", f.Severity, findingLocation(f), firstLine(f.Message))
+    }
+    return sb.String()
+}
+
+// truncateLines 保留前 n 行，截断时注明省略的行数
+func truncateLines(s string, n int) string {
+    lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+    if len(lines) <= n {
+        return strings.Join(lines, "\n") + "\n"
+    }
+    return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n... (%d more lines)\n", len(lines)-n)
+}
+
+// parseSummary 解析并校验总览 JSON
+func parseSummary(content string) (*changesetSummary, error) {
+    raw := extractJSON(content)
+    if raw == "" {
+        return nil, errors.New("no JSON object found in output")
+    }
+    var s changesetSummary
+    if err := json.Unmarshal([]byte(raw), &s); err != nil {
+        return nil, fmt.Errorf("invalid JSON: %v", err)
+    }
+    s.Risk = strings.ToLower(strings.TrimSpace(s.Risk))
+    switch s.Risk {
+    case "low", "medium", "high":
+    default:
+        return nil, fmt.Errorf("unknown risk: %q (expect low/medium/high)", s.Risk)
+    }
+    if strings.TrimSpace(s.Overview) == "" {
+        return nil, errors.New("overview is required")
+    }
+    return &s, nil
+}
+
+// summarySystemPrompt 变更集总览的 prompt 与输出格式约定
+func summarySystemPrompt(language string) string {
+    if language == "en" {
+        return `You are a senior engineer reviewing a whole change set. The user gives you the list of changed files, a truncated diff of each file and the findings of the per-file reviews. Assess the change as a whole rather than repeating per-file findings: the overall risk of merging it, inconsistencies between files (e.g. a signature changed in one file but not its callers, config and code out of sync), and changed behaviour that lacks tests. Then suggest a commit / PR description.
+Respond with a single JSON object only, without any other text, in the following format:
+{"risk": "low|medium|high", "overview": "a few sentences on what the change does and its overall quality", "cross_file_issues": ["one inconsistency between files per item"], "missing_tests": ["one missing test per item"], "commit_title": "a commit title within 72 characters", "commit_body": "a commit / PR description, markdown allowed"}
+Use empty arrays when there is nothing to report. Write everything in English.`
+    }
+    return `你是一位资深工程师，正在审查整个变更集。用户会给出变更文件列表、每个文件截断后的 diff 以及逐文件审查发现的问题。请从整体上评估变更，而不是重复逐文件的问题：合入的整体风险、文件之间的不一致（例如一处修改了函数签名而调用方未同步、配置与代码不一致），以及缺少测试的行为变更。最后给出建议的提交 / PR 说明。
+只输出一个 JSON 对象，不要包含任何其他文字，格式如下：
+{"risk": "low|medium|high", "overview": "用几句话说明变更内容与整体质量", "cross_file_issues": ["每项一条跨文件不一致"], "missing_tests": ["每项一条缺失的测试"], "commit_title": "72 字符以内的提交标题", "commit_body": "提交 / PR 说明，可使用 markdown"}
+没有内容时使用空数组。全部使用中文。`
+}
+
+// formatSummary 将变更集总览渲染为报告开头的 Markdown 段落
+func (e *Engine) formatSummary(s *changesetSummary) string {
+    en := e.cfg.Language == "en"
+    label := func(zh, english string) string {
+        if en {
+            return english
+        }
+        return zh
+    }
+
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "\n## %s\n\n", label("变更集总览", "Change Set Summary"))
+    if s.Raw != "" {
+        sb.WriteString(strings.TrimSpace(s.Raw))
+        sb.WriteString("\n\n---\n\n")
+        return sb.String()
+    }
+    fmt.Fprintf(&sb, "**%s**: %s %s\n\n", label("整体风险", "Overall Risk"), riskIcon(s.Risk), s.Risk)
+    sb.WriteString(strings.TrimSpace(s.Overview))
+    sb.WriteString("\n\n")
+
+    list := func(title string, items []string) {
+        if len(items) == 0 {
+            return
+        }
+        fmt.Fprintf(&sb, "### %s\n\n", title)
+        for _, item := range items {
+            fmt.Fprintf(&sb, "- %s\n", strings.TrimSpace(item))
+        }
+        sb.WriteString("\n")
+    }
+    list(label("跨文件问题", "Cross-file Issues"), s.CrossFileIssues)
+    list(label("缺失的测试", "Missing Tests"), s.MissingTests)
+
+    if s.CommitTitle != "" {
+        fmt.Fprintf(&sb, "### %s\n\n```text\n%s\n", label("建议的提交说明", "Suggested Commit Message"), strings.TrimSpace(s.CommitTitle))
+        if body := strings.TrimSpace(s.CommitBody); body != "" {
+            fmt.Fprintf(&sb, "\n%s\n", body)
+        }
+        sb.WriteString("```\n\n")
+    }
+    sb.WriteString("---\n\n")
+    return sb.String()
+}
+
+func riskIcon(risk string) string {
+    switch risk {
+    case "high":
+        return "🔴"
+    case "medium":
+        return "🟠"
+    default:
+        return "🟢"
+    }
+}
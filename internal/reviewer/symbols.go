@@ -0,0 +1,355 @@
+package reviewer
+
+import (
+    "bytes"
+    "fmt"
+    "go/ast"
+    "go/parser"
+    "go/printer"
+    "go/token"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/fatih/color"
+)
+
+const (
+    // 每个变更符号附带的引用数上限
+    maxSymbolRefs = 8
+    // 单个文件附带的引用总数上限
+    maxSymbolRefsTotal = 30
+)
+
+// changedSymbol Go 文件中签名或定义发生变化的符号
+type changedSymbol struct {
+    name string
+    recv string // 方法的接收者类型，函数与类型为空
+    kind string // func / method / type / interface
+    old  string
+    new  string // 为空表示符号被删除
+    // 接口的方法名，用于查找实现
+    methods []string
+    // 新文件中定义所在的行号区间，删除的符号为 0
+    start, end int
+}
+
+// goDecl 单个顶层声明的签名
+type goDecl struct {
+    changedSymbol
+    sig string
+}
+
+// goIndex 仓库中所有 Go 文件的标识符引用与方法定义，首次使用时构建
+type goIndex struct {
+    files map[string]*goIndexedFile
+}
+
+type goIndexedFile struct {
+    pkg     string
+    dir     string
+    lines   []string
+    refs    []goRef
+    methods []goMethod
+}
+
+// goRef 标识符的一次使用；qualifier 为选择器表达式 X.Sel 中的 X（非标识符时为 "?"），单独的标识符为空
+type goRef struct {
+    name      string
+    qualifier string
+    line      int
+}
+
+// goMethod 方法定义
+type goMethod struct {
+    recv string
+    name string
+    line int
+}
+
+// symbolContext 找出 Go 文件中签名变化或删除的函数、方法与导出类型，附带仓库中调用方与接口实现的片段
+//
+// 只处理本次变更（或拆分后的本部分）涉及的符号；删除的符号附在第一部分
+func (e *Engine) symbolContext(d gitDiff) string {
+    if !strings.EqualFold(path.Ext(d.FilePath), ".go") || d.OldContent == "" || d.NewContent == "" || e.cfg.Context == ContextNone || e.walker == nil {
+        return ""
+    }
+    symbols, pkg := changedGoSymbols(d.FilePath, d.OldContent, d.NewContent)
+    if len(symbols) == 0 {
+        return ""
+    }
+    touched := touchedLines(d.Content)
+    var relevant []changedSymbol
+    for _, s := range symbols {
+        if s.new == "" && d.Chunk <= 1 || s.new != "" && touchesRange(touched, s.start, s.end) {
+            relevant = append(relevant, s)
+        }
+    }
+    if len(relevant) == 0 {
+        return ""
+    }
+
+    index := e.loadGoIndex()
+    var sb strings.Builder
+    total := 0
+    for _, s := range relevant {
+        refs := index.references(s, d.FilePath, pkg)
+        if len(refs) == 0 {
+            continue
+        }
+        if total == 0 {
+            if e.cfg.Language == "en" {
+                sb.WriteString("\n\nOther places in the repository that use the changed Go symbols (check whether they still compile and behave correctly):\n")
+            } else {
+                sb.WriteString("\n\n仓库中使用了这些变更 Go 符号的位置（请检查它们是否仍能编译、行为是否正确）：\n")
+            }
+        }
+        if s.new == "" {
+            fmt.Fprintf(&sb, "* %s (removed)\n", s.old)
+        } else {
+            fmt.Fprintf(&sb, "* %s\n  -> %s\n", s.old, s.new)
+        }
+        for i, r := range refs {
+            if i == maxSymbolRefs || total >= maxSymbolRefsTotal {
+                fmt.Fprintf(&sb, "  ... (+%d)\n", len(refs)-i)
+                break
+            }
+            fmt.Fprintf(&sb, "  %s\n", r)
+            total++
+        }
+    }
+    return sb.String()
+}
+
+func touchesRange(lines []int, start, end int) bool {
+    for _, l := range lines {
+        if l >= start && l <= end {
+            return true
+        }
+    }
+    return false
+}
+
+// changedGoSymbols 对比变更前后的顶层声明：签名变化或被删除的函数、方法，以及定义变化或被删除的导出类型
+func changedGoSymbols(file, oldContent, newContent string) ([]changedSymbol, string) {
+    oldFile, oldDecls := goSignatures(file, oldContent)
+    newFile, newDecls := goSignatures(file, newContent)
+    if oldFile == nil || newFile == nil {
+        return nil, ""
+    }
+
+    var changed []changedSymbol
+    for key, o := range oldDecls {
+        n, ok := newDecls[key]
+        if ok && n.sig == o.sig {
+            continue
+        }
+        s := o.changedSymbol
+        s.old = o.sig
+        s.start, s.end = 0, 0
+        if ok {
+            s.new = n.sig
+            s.start, s.end = n.start, n.end
+            s.methods = n.methods
+        }
+        changed = append(changed, s)
+    }
+    sort.Slice(changed, func(i, j int) bool { return changed[i].old < changed[j].old })
+    return changed, newFile.Name.Name
+}
+
+// goSignatures 解析文件中的函数、方法签名与导出类型定义
+func goSignatures(file, content string) (*ast.File, map[string]goDecl) {
+    fset := token.NewFileSet()
+    f, err := parser.ParseFile(fset, file, content, 0)
+    if err != nil {
+        return nil, nil
+    }
+    printNode := func(node any) string {
+        var buf bytes.Buffer
+        _ = printer.Fprint(&buf, fset, node)
+        return strings.Join(strings.Fields(buf.String()), " ")
+    }
+
+    decls := map[string]goDecl{}
+    for _, decl := range f.Decls {
+        switch d := decl.(type) {
+        case *ast.FuncDecl:
+            // 只比较签名，函数体的变化由 diff 本身覆盖
+            body := d.Body
+            d.Body = nil
+            sig := printNode(d)
+            d.Body = body
+            s := changedSymbol{name: d.Name.Name, kind: "func"}
+            key := d.Name.Name
+            if d.Recv != nil && len(d.Recv.List) > 0 {
+                s.kind = "method"
+                s.recv = receiverType(d.Recv.List[0].Type)
+                key = s.recv + "." + key
+            }
+            s.start, s.end = fset.Position(d.Pos()).Line, fset.Position(d.End()).Line
+            decls[key] = goDecl{changedSymbol: s, sig: sig}
+        case *ast.GenDecl:
+            if d.Tok != token.TYPE {
+                continue
+            }
+            for _, spec := range d.Specs {
+                ts := spec.(*ast.TypeSpec)
+                if !ts.Name.IsExported() {
+                    continue
+                }
+                s := changedSymbol{name: ts.Name.Name, kind: "type"}
+                if it, ok := ts.Type.(*ast.InterfaceType); ok {
+                    s.kind = "interface"
+                    for _, m := range it.Methods.List {
+                        for _, n := range m.Names {
+                            s.methods = append(s.methods, n.Name)
+                        }
+                    }
+                }
+                s.start, s.end = fset.Position(ts.Pos()).Line, fset.Position(ts.End()).Line
+                decls["type "+ts.Name.Name] = goDecl{changedSymbol: s, sig: "type " + printNode(ts)}
+            }
+        }
+    }
+    return f, decls
+}
+
+// receiverType 接收者的类型名，去掉指针与类型参数
+func receiverType(expr ast.Expr) string {
+    switch t := expr.(type) {
+    case *ast.StarExpr:
+        return receiverType(t.X)
+    case *ast.IndexExpr:
+        return receiverType(t.X)
+    case *ast.IndexListExpr:
+        return receiverType(t.X)
+    case *ast.Ident:
+        return t.Name
+    }
+    return ""
+}
+
+// loadGoIndex 遍历仓库中的 Go 文件建立索引，整个运行期间只构建一次
+func (e *Engine) loadGoIndex() *goIndex {
+    e.goIndexOnce.Do(func() {
+        index := &goIndex{files: map[string]*goIndexedFile{}}
+        err := e.walker(func(p, content string) {
+            if !strings.HasSuffix(p, ".go") {
+                return
+            }
+            if f := indexGoFile(p, content); f != nil {
+                index.files[p] = f
+            }
+        })
+        if err != nil {
+            color.Red("failed to index go files: err=%v\n", err)
+        }
+        e.goIndex = index
+    })
+    return e.goIndex
+}
+
+// indexGoFile 记录文件中除声明名之外的所有标识符使用与方法定义
+func indexGoFile(p, content string) *goIndexedFile {
+    fset := token.NewFileSet()
+    f, err := parser.ParseFile(fset, p, content, parser.SkipObjectResolution)
+    if err != nil {
+        return nil
+    }
+    indexed := &goIndexedFile{pkg: f.Name.Name, dir: path.Dir(p), lines: strings.Split(content, "\n")}
+
+    declNames := map[*ast.Ident]bool{}
+    for _, decl := range f.Decls {
+        switch d := decl.(type) {
+        case *ast.FuncDecl:
+            declNames[d.Name] = true
+            if d.Recv != nil && len(d.Recv.List) > 0 {
+                indexed.methods = append(indexed.methods, goMethod{
+                    recv: receiverType(d.Recv.List[0].Type),
+                    name: d.Name.Name,
+                    line: fset.Position(d.Pos()).Line,
+                })
+            }
+        case *ast.GenDecl:
+            for _, spec := range d.Specs {
+                if ts, ok := spec.(*ast.TypeSpec); ok {
+                    declNames[ts.Name] = true
+                }
+            }
+        }
+    }
+
+    // 选择器的 Sel 部分以限定形式记录，不再作为单独的标识符
+    selectors := map[*ast.Ident]bool{}
+    ast.Inspect(f, func(n ast.Node) bool {
+        switch x := n.(type) {
+        case *ast.SelectorExpr:
+            qualifier := "?"
+            if id, ok := x.X.(*ast.Ident); ok {
+                qualifier = id.Name
+            }
+            selectors[x.Sel] = true
+            indexed.refs = append(indexed.refs, goRef{name: x.Sel.Name, qualifier: qualifier, line: fset.Position(x.Sel.Pos()).Line})
+        case *ast.Ident:
+            if !declNames[x] && !selectors[x] {
+                indexed.refs = append(indexed.refs, goRef{name: x.Name, line: fset.Position(x.Pos()).Line})
+            }
+        }
+        return true
+    })
+    return indexed
+}
+
+// references 查找变更符号在仓库中的使用位置，接口额外列出方法名匹配的实现
+//
+// 函数与类型：同目录（同包）中的直接引用，或其他包中以包名限定的引用；方法：任意 X.Name 形式的选择器
+func (idx *goIndex) references(s changedSymbol, file, pkg string) []string {
+    dir := path.Dir(file)
+    paths := make([]string, 0, len(idx.files))
+    for p := range idx.files {
+        paths = append(paths, p)
+    }
+    sort.Strings(paths)
+
+    var out []string
+    for _, p := range paths {
+        f := idx.files[p]
+        seen := map[int]bool{}
+        for _, r := range f.refs {
+            if r.name != s.name || seen[r.line] {
+                continue
+            }
+            var match bool
+            switch s.kind {
+            case "method":
+                match = r.qualifier != ""
+            default:
+                match = (r.qualifier == "" && f.dir == dir && f.pkg == pkg) || (r.qualifier == pkg && f.dir != dir)
+            }
+            if !match || (p == file && r.line >= s.start && r.line <= s.end) {
+                continue
+            }
+            seen[r.line] = true
+            out = append(out, fmt.Sprintf("%s:%d: %s", p, r.line, excerpt(f.lines, r.line)))
+        }
+        if s.kind == "interface" && len(s.methods) > 0 {
+            for _, m := range f.methods {
+                for _, name := range s.methods {
+                    if m.name == name {
+                        out = append(out, fmt.Sprintf("%s:%d: (implements %s.%s?) %s", p, m.line, s.name, name, excerpt(f.lines, m.line)))
+                    }
+                }
+            }
+        }
+    }
+    return out
+}
+
+// excerpt 引用所在行，过长时截断
+func excerpt(lines []string, line int) string {
+    if line < 1 || line > len(lines) {
+        return ""
+    }
+    return clipLine(lines[line-1])
+}
@@ -0,0 +1,86 @@
+package reviewer
+
+import (
+    "context"
+    "errors"
+    "io"
+    "strings"
+
+    "github.com/cloudwego/eino-ext/components/model/claude"
+    aclopenai "github.com/cloudwego/eino-ext/libs/acl/openai"
+    "github.com/cloudwego/eino/compose"
+    "github.com/cloudwego/eino/schema"
+    "github.com/fatih/color"
+)
+
+// reasoningOf 提取推理模型（deepseek-reasoner、o 系列、Claude 等）返回的思考过程
+func reasoningOf(msg *schema.Message) string {
+    if msg == nil {
+        return ""
+    }
+    if msg.ReasoningContent != "" {
+        return msg.ReasoningContent
+    }
+    if rc, ok := aclopenai.GetReasoningContent(msg); ok {
+        return rc
+    }
+    // Anthropic 开启 extended thinking 时的思考内容
+    rc, _ := claude.GetThinking(msg)
+    return rc
+}
+
+// streamWithThinking 以流式方式调用模型，边接收边将思考过程逐行输出到终端，最终拼接为完整消息
+func (e *Engine) streamWithThinking(ctx context.Context, r compose.Runnable[map[string]any, *schema.Message], input map[string]any, filePath string) (*schema.Message, error) {
+    sr, err := r.Stream(ctx, input)
+    if err != nil {
+        return nil, err
+    }
+    defer sr.Close()
+
+    printer := &thinkingPrinter{filePath: filePath}
+    defer printer.flush()
+
+    var chunks []*schema.Message
+    for {
+        chunk, err := sr.Recv()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        chunks = append(chunks, chunk)
+        printer.write(reasoningOf(chunk))
+    }
+    if len(chunks) == 0 {
+        return nil, errors.New("empty stream response")
+    }
+    return schema.ConcatMessages(chunks)
+}
+
+// thinkingPrinter 按行输出思考过程；多文件并发时每行带上文件路径前缀以便区分
+type thinkingPrinter struct {
+    filePath string
+    buf      strings.Builder
+}
+
+func (p *thinkingPrinter) write(s string) {
+    for s != "" {
+        i := strings.IndexByte(s, '\n')
+        if i < 0 {
+            p.buf.WriteString(s)
+            return
+        }
+        p.buf.WriteString(s[:i])
+        p.flush()
+        s = s[i+1:]
+    }
+}
+
+func (p *thinkingPrinter) flush() {
+    if p.buf.Len() == 0 {
+        return
+    }
+    color.New(color.Faint).Printf("💭 %s | %s\n", p.filePath, p.buf.String())
+    p.buf.Reset()
+}
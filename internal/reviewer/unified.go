@@ -0,0 +1,126 @@
+package reviewer
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffLine 行级 diff 中的一行
+type diffLine struct {
+    op   diffmatchpatch.Operation
+    text string // 不含换行符
+    // 缺少结尾换行的最后一行，需要输出 "\ No newline at end of file"
+    noEOL bool
+}
+
+// unifiedDiff 生成带 @@ hunk 头与 +/- 标记的标准 unified diff，contextLines 为每个 hunk 前后保留的上下文行数
+func unifiedDiff(oldPath, newPath, oldContent, newContent string, contextLines int) string {
+    if oldContent == newContent {
+        return ""
+    }
+    if contextLines < 0 {
+        contextLines = 0
+    }
+
+    lines := diffLines(oldContent, newContent)
+
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "--- a/%s\n", oldPath)
+    fmt.Fprintf(&sb, "+++ b/%s\n", newPath)
+
+    // oldNo/newNo 记录 lines[i] 之前已经消耗的行数
+    oldNo := make([]int, len(lines)+1)
+    newNo := make([]int, len(lines)+1)
+    for i, l := range lines {
+        oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+        if l.op != diffmatchpatch.DiffInsert {
+            oldNo[i+1]++
+        }
+        if l.op != diffmatchpatch.DiffDelete {
+            newNo[i+1]++
+        }
+    }
+
+    for i := 0; i < len(lines); {
+        if lines[i].op == diffmatchpatch.DiffEqual {
+            i++
+            continue
+        }
+
+        // 向后扩展 hunk：两个变更之间的相等行不超过 2*contextLines 时合并
+        start := max(i-contextLines, 0)
+        end := i
+        for end < len(lines) {
+            if lines[end].op != diffmatchpatch.DiffEqual {
+                end++
+                continue
+            }
+            run := end
+            for run < len(lines) && lines[run].op == diffmatchpatch.DiffEqual {
+                run++
+            }
+            if run == len(lines) || run-end > 2*contextLines {
+                end = min(end+contextLines, len(lines))
+                break
+            }
+            end = run
+        }
+
+        writeHunk(&sb, lines[start:end], oldNo[start], oldNo[end]-oldNo[start], newNo[start], newNo[end]-newNo[start])
+        i = end
+    }
+    return sb.String()
+}
+
+// writeHunk 输出单个 hunk，oldStart/newStart 为 hunk 之前的行数（0-based）
+func writeHunk(sb *strings.Builder, lines []diffLine, oldStart, oldCount, newStart, newCount int) {
+    fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+    for _, l := range lines {
+        switch l.op {
+        case diffmatchpatch.DiffInsert:
+            sb.WriteByte('+')
+        case diffmatchpatch.DiffDelete:
+            sb.WriteByte('-')
+        default:
+            sb.WriteByte(' ')
+        }
+        sb.WriteString(l.text)
+        sb.WriteByte('\n')
+        if l.noEOL {
+            sb.WriteString("\\ No newline at end of file\n")
+        }
+    }
+}
+
+// hunkRange 按 GNU diff 规则格式化行号区间：空区间的起始行为前一行，单行时省略行数
+func hunkRange(start, count int) string {
+    if count == 0 {
+        return fmt.Sprintf("%d,0", start)
+    }
+    if count == 1 {
+        return fmt.Sprintf("%d", start+1)
+    }
+    return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines 使用 diffmatchpatch 的行模式计算逐行差异
+func diffLines(oldContent, newContent string) []diffLine {
+    dmp := diffmatchpatch.New()
+    a, b, lineArray := dmp.DiffLinesToChars(oldContent, newContent)
+    diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+    var lines []diffLine
+    for _, d := range diffs {
+        for _, text := range strings.SplitAfter(d.Text, "\n") {
+            if text == "" {
+                continue
+            }
+            l := diffLine{op: d.Type, text: strings.TrimSuffix(text, "\n")}
+            l.noEOL = !strings.HasSuffix(text, "\n")
+            lines = append(lines, l)
+        }
+    }
+    return lines
+}
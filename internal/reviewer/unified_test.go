@@ -0,0 +1,84 @@
+package reviewer
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+    tests := []struct {
+        name         string
+        old, new     string
+        contextLines int
+        want         string
+    }{
+        {
+            name:         "identical",
+            old:          "a\nb\n",
+            new:          "a\nb\n",
+            contextLines: 3,
+            want:         "",
+        },
+        {
+            name:         "modify with context",
+            old:          "a\nb\nc\nd\ne\n",
+            new:          "a\nb\nX\nd\ne\n",
+            contextLines: 1,
+            want:         "--- a/f\n+++ b/f\n@@ -2,3 +2,3 @@\n b\n-c\n+X\n d\n",
+        },
+        {
+            name:         "no newline on both sides",
+            old:          "a\nb",
+            new:          "a\nc",
+            contextLines: 3,
+            want:         "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+        },
+        {
+            name:         "newline added at end",
+            old:          "a\nb",
+            new:          "a\nb\n",
+            contextLines: 3,
+            want:         "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+        },
+        {
+            name:         "zero context separate hunks",
+            old:          "1\n2\n3\n4\n5\n6\n",
+            new:          "1\nX\n3\n4\nY\n6\n",
+            contextLines: 0,
+            want:         "--- a/f\n+++ b/f\n@@ -2 +2 @@\n-2\n+X\n@@ -5 +5 @@\n-5\n+Y\n",
+        },
+        {
+            name:         "zero context insertion",
+            old:          "1\n2\n",
+            new:          "1\nnew\n2\n",
+            contextLines: 0,
+            want:         "--- a/f\n+++ b/f\n@@ -1,0 +2 @@\n+new\n",
+        },
+        {
+            name:         "zero context deletion",
+            old:          "1\n2\n3\n",
+            new:          "1\n3\n",
+            contextLines: 0,
+            want:         "--- a/f\n+++ b/f\n@@ -2 +1,0 @@\n-2\n",
+        },
+        {
+            name:         "new file",
+            old:          "",
+            new:          "a\n",
+            contextLines: 3,
+            want:         "--- a/f\n+++ b/f\n@@ -0,0 +1 @@\n+a\n",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := unifiedDiff("f", "f", tt.old, tt.new, tt.contextLines); got != tt.want {
+                t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestUnifiedDiffRenameHeaders(t *testing.T) {
+    got := unifiedDiff("old.go", "new.go", "a\n", "b\n", 3)
+    want := "--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-a\n+b\n"
+    if got != want {
+        t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+    }
+}